@@ -0,0 +1,71 @@
+package zmq3
+
+/*
+#include <zmq.h>
+int zmq3_send_batch(void *soc, char *data, size_t *sizes, int n, int flags) {
+    int i;
+    for (i = 0; i < n; i++) {
+        if (zmq_send(soc, data, sizes[i], flags) < 0)
+            break;
+        data += sizes[i];
+    }
+    return i;
+}
+*/
+import "C"
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+/*
+Send a batch of single-part messages.
+
+Each element of frames is sent as a separate message, using the same flags.
+This does the same as calling SendBytes() for each frame, but with far
+less overhead per message, which makes a difference for lots of small
+messages.
+
+Returns the number of messages sent. If an error occurs, for instance
+EAGAIN with flag DONTWAIT, the remaining messages are not sent.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-send#toc2
+*/
+func (soc *Socket) SendBatch(frames [][]byte, flags Flag) (int, error) {
+	if soc.soc == nil {
+		return 0, ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
+	if len(frames) == 0 {
+		return 0, nil
+	}
+
+	total := 0
+	for _, frame := range frames {
+		total += len(frame)
+	}
+	data := make([]byte, total+1)
+	sizes := make([]C.size_t, len(frames))
+	total = 0
+	for i, frame := range frames {
+		total += copy(data[total:], frame)
+		sizes[i] = C.size_t(len(frame))
+	}
+
+	sent := 0
+	offset := 0
+	for {
+		n, err := C.zmq3_send_batch(soc.soc, (*C.char)(unsafe.Pointer(&data[offset])), &sizes[sent], C.int(len(frames)-sent), C.int(flags))
+		for _, size := range sizes[sent : sent+int(n)] {
+			offset += int(size)
+		}
+		sent += int(n)
+		if sent == len(frames) {
+			return sent, nil
+		}
+		if !soc.retry(err, flags) {
+			return sent, errget(err)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package zmq3
+
+/*
+#include <stdint.h>
+*/
+import "C"
+
+// Called by 0MQ, through zmq3_free_data, when it is done with the data of a zero-copy message.
+//export zmq3FreeCallback
+func zmq3FreeCallback(hint C.uintptr_t) {
+	zeroCopyRelease(uintptr(hint))
+}
@@ -0,0 +1,59 @@
+package zmq3
+
+/*
+#include <zmq.h>
+*/
+import "C"
+
+import (
+	"sync"
+	"syscall"
+)
+
+// Features of the 0MQ library, as returned by Capabilities()
+type Caps struct {
+	IPC   bool // transport ipc://
+	PGM   bool // transports pgm:// and epgm://
+	CURVE bool // CURVE security, always false: this needs 0MQ version 4
+}
+
+var (
+	caps     Caps
+	capsOnce sync.Once
+)
+
+/*
+Report which optional features the 0MQ library supports.
+
+0MQ version 3 has no function to query this. Support for transports is
+detected by connecting test sockets, in a separate context. This is done
+only once, later calls return the same result.
+*/
+func Capabilities() Caps {
+	capsOnce.Do(func() {
+		caps.IPC = hasTransport("ipc://zmq3.caps")
+		caps.PGM = hasTransport("epgm://127.0.0.1;239.192.1.1:5555")
+	})
+	return caps
+}
+
+func hasTransport(endpoint string) bool {
+	ctx, err := NewContext()
+	if err != nil {
+		return false
+	}
+	defer ctx.Term()
+	soc, err := ctx.NewSocket(SUB)
+	if err != nil {
+		return false
+	}
+	defer soc.Close()
+	soc.SetLinger(0)
+	switch e := soc.Connect(endpoint).(type) {
+	case syscall.Errno:
+		return e != C.EPROTONOSUPPORT
+	case Errno:
+		return e != C.EPROTONOSUPPORT
+	}
+	return true
+}
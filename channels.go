@@ -0,0 +1,176 @@
+package zmq3
+
+import (
+	"time"
+)
+
+// How often the channel goroutine checks for messages to send.
+const channelPollInterval = 10 * time.Millisecond
+
+type socketChannels struct {
+	send chan []byte
+	recv chan []byte
+	errs chan error
+}
+
+/*
+Returns a channel for sending message parts on the socket.
+
+The first call to SendChannel(), RecvChannel() or ErrorChannel() starts a
+goroutine that owns the socket: from then on, the socket must not be used
+directly, only through these channels.
+
+Each []byte written to the channel is sent as a single message part.
+The goroutine never blocks on the socket: a part the socket can't accept
+yet, for instance at the high-water mark, is held until it can be sent,
+while receiving goes on.
+
+Closing the channel stops the goroutine, after which RecvChannel() and
+ErrorChannel() are closed. The goroutine also stops after reporting an
+error that makes the socket unusable, such as ETERM when the context is
+terminated. Then the socket can be used directly again,
+or closed, or SendChannel() can be called again to start a new goroutine
+with new channels. Wait for RecvChannel() to be closed before doing so.
+A part held by the goroutine when it stops is dropped.
+*/
+func (soc *Socket) SendChannel() chan<- []byte {
+	return soc.channels().send
+}
+
+/*
+Returns a channel for receiving message parts from the socket.
+
+See: func (*Socket) SendChannel
+*/
+func (soc *Socket) RecvChannel() <-chan []byte {
+	return soc.channels().recv
+}
+
+/*
+Returns a channel with errors from sending and receiving by the goroutine
+that owns the socket. Errors are dropped if the channel isn't read.
+
+See: func (*Socket) SendChannel
+*/
+func (soc *Socket) ErrorChannel() <-chan error {
+	return soc.channels().errs
+}
+
+func (soc *Socket) channels() *socketChannels {
+	soc.chansMu.Lock()
+	defer soc.chansMu.Unlock()
+	if soc.chans == nil {
+		soc.chans = &socketChannels{
+			send: make(chan []byte),
+			recv: make(chan []byte),
+			errs: make(chan error, 10),
+		}
+		go soc.chans.run(soc)
+	}
+	return soc.chans
+}
+
+func (c *socketChannels) error(err error) {
+	select {
+	case c.errs <- err:
+	default:
+	}
+}
+
+func (c *socketChannels) run(soc *Socket) {
+	defer close(c.errs)
+	defer close(c.recv)
+	// before closing the channels, so the next call to channels() starts anew
+	defer func() {
+		soc.chansMu.Lock()
+		if soc.chans == c {
+			soc.chans = nil
+		}
+		soc.chansMu.Unlock()
+	}()
+
+	var out []byte // part waiting for the socket to accept it
+	sending := false
+	var in []byte // part waiting to be read from the receive channel
+	receiving := false
+
+	p := NewPoller()
+	p.Add(soc, POLLIN)
+	for {
+		// send what the socket accepts without blocking
+		for {
+			if !sending {
+				select {
+				case b, ok := <-c.send:
+					if !ok {
+						return
+					}
+					out, sending = b, true
+				default:
+				}
+			}
+			if !sending {
+				break
+			}
+			if _, err := soc.SendBytes(out, DONTWAIT); err == ErrEAGAIN {
+				break
+			} else if err != nil {
+				c.error(err)
+				if isFatal(err) {
+					return
+				}
+			}
+			sending = false
+		}
+
+		if receiving {
+			// keep sending while waiting for the message to be read
+			var send <-chan []byte
+			var retry <-chan time.Time
+			if sending {
+				retry = time.After(channelPollInterval)
+			} else {
+				send = c.send
+			}
+			select {
+			case c.recv <- in:
+				receiving = false
+			case b, ok := <-send:
+				if !ok {
+					return
+				}
+				out, sending = b, true
+			case <-retry:
+			}
+			continue
+		}
+
+		events := POLLIN
+		if sending {
+			events |= POLLOUT
+		}
+		p.Update(soc, events)
+		polled, err := p.Poll(channelPollInterval)
+		if err != nil {
+			c.error(err)
+			if isFatal(err) {
+				return
+			}
+			continue
+		}
+		if len(polled) == 0 || polled[0].Events&POLLIN == 0 {
+			continue
+		}
+		b, err := soc.RecvBytes(DONTWAIT)
+		if err != nil {
+			if err != ErrEAGAIN {
+				c.error(err)
+			}
+			if isFatal(err) {
+				return
+			}
+			continue
+		}
+		in, receiving = b, true
+	}
+}
@@ -0,0 +1,84 @@
+package zmq3
+
+// Integer options copied by Clone()
+var cloneOptions = []int{
+	OPT_SNDHWM,
+	OPT_RCVHWM,
+	OPT_RATE,
+	OPT_RECOVERY_IVL,
+	OPT_SNDBUF,
+	OPT_RCVBUF,
+	OPT_LINGER,
+	OPT_RECONNECT_IVL,
+	OPT_RECONNECT_IVL_MAX,
+	OPT_BACKLOG,
+	OPT_MULTICAST_HOPS,
+	OPT_RCVTIMEO,
+	OPT_SNDTIMEO,
+	OPT_IPV4ONLY,
+	OPT_DELAY_ATTACH_ON_CONNECT,
+	OPT_TCP_KEEPALIVE,
+	OPT_TCP_KEEPALIVE_CNT,
+	OPT_TCP_KEEPALIVE_IDLE,
+	OPT_TCP_KEEPALIVE_INTVL,
+}
+
+/*
+Create a new socket of the same type, in the same context, with the same
+configuration.
+
+Copied are all options that can be retrieved with a Get... function, and
+the subscriptions set with SetSubscribe() or SetSockOptString(). The
+identity is not copied, since each socket needs a unique identity.
+Options that can only be set, such as ZMQ_ROUTER_MANDATORY,
+ZMQ_XPUB_VERBOSE and ZMQ_TCP_ACCEPT_FILTER, are not copied either.
+
+The new socket is not bound or connected to anything.
+*/
+func (soc *Socket) Clone() (*Socket, error) {
+	t, err := soc.GetType()
+	if err != nil {
+		return nil, err
+	}
+	c, err := soc.ctx.NewSocket(t)
+	if err != nil {
+		return nil, err
+	}
+	if err := soc.cloneInto(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (soc *Socket) cloneInto(c *Socket) error {
+	for _, opt := range cloneOptions {
+		v, err := soc.GetSockOptInt(opt)
+		if err != nil {
+			return err
+		}
+		if err := c.SetSockOptInt(opt, v); err != nil {
+			return err
+		}
+	}
+	affinity, err := soc.GetAffinity()
+	if err != nil {
+		return err
+	}
+	if err := c.SetAffinity(affinity); err != nil {
+		return err
+	}
+	maxmsgsize, err := soc.GetMaxmsgsize()
+	if err != nil {
+		return err
+	}
+	if err := c.SetMaxmsgsize(maxmsgsize); err != nil {
+		return err
+	}
+	for _, filter := range soc.subscriptions {
+		if err := c.SetSubscribe(filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package zmq3
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+type conn struct {
+	reader
+	writer
+	soc       *Socket
+	rDeadline time.Time
+	wDeadline time.Time
+}
+
+/*
+Returns a net.Conn that reads and writes on the socket.
+
+This is meant for PAIR sockets, where there is a single peer.
+
+Each Write sends one message part. Each message part received is returned by
+one or more calls to Read: if a message part is larger than the buffer,
+the remainder is returned by the next Read. Empty message parts are skipped.
+
+The deadlines are implemented with the options ZMQ_RCVTIMEO and ZMQ_SNDTIMEO,
+overwriting their values. Both LocalAddr() and RemoteAddr() return the
+last endpoint the socket was bound or connected to.
+
+Closing the net.Conn closes the socket.
+*/
+func (soc *Socket) AsConn() net.Conn {
+	return &conn{reader: reader{soc: soc}, writer: writer{soc: soc}, soc: soc}
+}
+
+// Address of a socket, as returned by the net.Conn from (*Socket)AsConn()
+type endpointAddr string
+
+// Transport part of the endpoint, such as "tcp"
+func (a endpointAddr) Network() string {
+	s := string(a)
+	if i := strings.Index(s, "://"); i >= 0 {
+		return s[:i]
+	}
+	return ""
+}
+
+// The endpoint, such as "tcp://127.0.0.1:5555"
+func (a endpointAddr) String() string {
+	return string(a)
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func deadlineTimeout(deadline time.Time) time.Duration {
+	if deadline.IsZero() {
+		return -1
+	}
+	d := deadline.Sub(time.Now())
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (c *conn) Read(b []byte) (n int, err error) {
+	if len(c.buf) == 0 {
+		if err = c.soc.SetRcvtimeo(deadlineTimeout(c.rDeadline)); err != nil {
+			return
+		}
+	}
+	n, err = c.reader.Read(b)
+	if err == ErrEAGAIN {
+		err = timeoutError{}
+	}
+	return
+}
+
+func (c *conn) Write(b []byte) (n int, err error) {
+	if err = c.soc.SetSndtimeo(deadlineTimeout(c.wDeadline)); err != nil {
+		return
+	}
+	n, err = c.writer.Write(b)
+	if err == ErrEAGAIN {
+		err = timeoutError{}
+	}
+	return
+}
+
+func (c *conn) Close() error {
+	return c.soc.Close()
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	s, _ := c.soc.GetLastEndpoint()
+	return endpointAddr(s)
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.LocalAddr()
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	c.rDeadline = t
+	c.wDeadline = t
+	return nil
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.rDeadline = t
+	return nil
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.wDeadline = t
+	return nil
+}
@@ -0,0 +1,82 @@
+package zmq3
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+/*
+Send a value, encoded as JSON, as a single message part.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-send#toc2
+*/
+func (soc *Socket) SendJSON(v interface{}, flags Flag) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("SendJSON: %w", err)
+	}
+	_, err = soc.SendBytes(data, flags)
+	return err
+}
+
+/*
+Receive a message part, and decode it from JSON into v.
+
+Errors from receiving are returned as is. Errors from decoding are wrapped,
+and can be inspected with errors.As(), for instance for a *json.SyntaxError.
+An empty message part is decoded as JSON null, leaving v unchanged.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-msg-recv#toc2
+*/
+func (soc *Socket) RecvJSON(v interface{}, flags Flag) error {
+	data, err := soc.RecvBytes(flags)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("RecvJSON: %w", err)
+	}
+	return nil
+}
+
+/*
+Send a value, encoded with encoding/gob, as a single message part.
+
+Each message is encoded with its own gob.Encoder, so it includes the type
+information, and each message can be decoded on its own. Concrete types
+sent as interface values must be registered with gob.Register(), by both
+the sender and the receiver.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-send#toc2
+*/
+func (soc *Socket) SendGob(v interface{}, flags Flag) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return fmt.Errorf("SendGob: %w", err)
+	}
+	_, err := soc.SendBytes(buf.Bytes(), flags)
+	return err
+}
+
+/*
+Receive a message part, and decode it with encoding/gob into v.
+
+Errors from receiving are returned as is. Errors from decoding are wrapped.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-msg-recv#toc2
+*/
+func (soc *Socket) RecvGob(v interface{}, flags Flag) error {
+	data, err := soc.RecvBytes(flags)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return fmt.Errorf("RecvGob: %w", err)
+	}
+	return nil
+}
@@ -18,6 +18,9 @@ import (
 )
 
 func main() {
+	//  Let interrupted calls return with EINTR, instead of restarting them
+	zmq.SetRetryAfterEINTR(false)
+
 	//  Socket to talk to server
 	fmt.Println("Connecting to hello world server...")
 	client, _ := zmq.NewSocket(zmq.REQ)
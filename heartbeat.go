@@ -0,0 +1,231 @@
+package zmq3
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+/*
+Application level heartbeating on a socket, for instance a DEALER
+connected to a ROUTER.
+
+The Heartbeater runs a goroutine that owns the socket: after calling
+NewHeartbeater(), the socket must not be used directly, only through the
+channels of the Heartbeater, until Stop() is called.
+
+The socket is never used in a way that blocks. Messages written to the
+Send() channel wait in the goroutine until the socket can queue them, so
+writing to that channel blocks while the peer doesn't accept messages.
+Meanwhile, pings that can't be queued are skipped.
+
+Every interval, a single-part message with the ping data is sent. Any
+message received counts as a sign of life from the peer. Received messages
+that consist of just the ping data are heartbeats from the peer, and are
+not passed on. While a received message waits to be read from Recv(),
+nothing more is received, and the peer is not reported dead.
+
+When Stop() is called, or after an error that makes the socket unusable,
+such as ETERM when the context is terminated, the goroutine stops, and
+the Recv() and Errors() channels are closed.
+*/
+type Heartbeater struct {
+	soc      *Socket
+	ping     []byte
+	interval time.Duration
+	timeout  time.Duration
+	send     chan [][]byte
+	recv     chan [][]byte
+	dead     chan bool
+	errs     chan error
+	stop     chan bool
+	done     chan bool
+	stopOnce sync.Once
+}
+
+/*
+Start heartbeating on the socket.
+
+If nothing is received from the peer for the duration of timeout,
+a value is sent on the channel returned by Dead().
+
+Example:
+
+    hb := zmq.NewHeartbeater(dealer, time.Second, 3*time.Second, []byte("PING"))
+    defer hb.Stop()
+    for {
+        select {
+        case msg := <-hb.Recv():
+            //  Process msg
+        case <-hb.Dead():
+            //  Peer is gone
+        }
+    }
+*/
+func NewHeartbeater(soc *Socket, interval, timeout time.Duration, ping []byte) *Heartbeater {
+	h := &Heartbeater{
+		soc:      soc,
+		ping:     ping,
+		interval: interval,
+		timeout:  timeout,
+		send:     make(chan [][]byte),
+		recv:     make(chan [][]byte),
+		dead:     make(chan bool, 1),
+		errs:     make(chan error, 10),
+		stop:     make(chan bool),
+		done:     make(chan bool),
+	}
+	go h.run()
+	return h
+}
+
+// Channel for sending multi-part messages on the socket.
+func (h *Heartbeater) Send() chan<- [][]byte {
+	return h.send
+}
+
+// Channel with multi-part messages received from the socket, except heartbeats.
+func (h *Heartbeater) Recv() <-chan [][]byte {
+	return h.recv
+}
+
+// Channel that receives a value each time the peer was silent for the duration of timeout.
+func (h *Heartbeater) Dead() <-chan bool {
+	return h.dead
+}
+
+// Channel with errors from sending and receiving. Errors are dropped if the channel isn't read.
+func (h *Heartbeater) Errors() <-chan error {
+	return h.errs
+}
+
+// Stop heartbeating. After this, the socket can be used directly again.
+//
+// It is safe to call Stop more than once.
+func (h *Heartbeater) Stop() {
+	h.stopOnce.Do(func() { close(h.stop) })
+	<-h.done
+}
+
+func (h *Heartbeater) error(err error) {
+	select {
+	case h.errs <- err:
+	default:
+	}
+}
+
+func (h *Heartbeater) run() {
+	defer close(h.done)
+	defer close(h.errs)
+	defer close(h.recv)
+
+	p := NewPoller()
+	p.Add(h.soc, POLLIN)
+	now := time.Now()
+	pingAt := now.Add(h.interval)
+	expiry := now.Add(h.timeout)
+	var incoming [][]byte // message waiting to be read from Recv()
+	receiving := false
+	var outgoing [][]byte
+	waiting := false
+
+	for {
+		var in chan [][]byte
+		if !waiting {
+			in = h.send
+		}
+		var out chan [][]byte
+		if receiving {
+			out = h.recv
+		}
+		select {
+		case <-h.stop:
+			return
+		case msg := <-in:
+			outgoing = msg
+			waiting = true
+		case out <- incoming:
+			incoming = nil
+			receiving = false
+			continue
+		default:
+		}
+
+		now = time.Now()
+		if !now.Before(pingAt) {
+			// if the ping can't be queued, that is just a missed beat
+			if _, err := h.soc.SendBytes(h.ping, DONTWAIT); err != nil && err != ErrEAGAIN {
+				h.error(err)
+				if isFatal(err) {
+					return
+				}
+			}
+			pingAt = now.Add(h.interval)
+		}
+		if receiving {
+			// nothing is received until the message is read
+			expiry = now.Add(h.timeout)
+		} else if !now.Before(expiry) {
+			select {
+			case h.dead <- true:
+			default:
+			}
+			expiry = now.Add(h.timeout)
+		}
+
+		// never block on sending, or a dead peer would block the goroutine
+		timeout := channelPollInterval
+		if waiting {
+			_, err := h.soc.SendMessageDontwait(outgoing)
+			if err != ErrEAGAIN {
+				if err != nil {
+					h.error(err)
+					if isFatal(err) {
+						return
+					}
+				}
+				outgoing = nil
+				waiting = false
+				timeout = 0
+			}
+		}
+		// don't receive more than can be passed on, so the receive
+		// high water mark of the socket limits what is buffered
+		events := State(0)
+		if !receiving {
+			events |= POLLIN
+		}
+		if waiting {
+			events |= POLLOUT
+		}
+		p.Update(h.soc, events)
+
+		polled, err := p.Poll(timeout)
+		if err != nil {
+			h.error(err)
+			if isFatal(err) {
+				return
+			}
+			continue
+		}
+		if len(polled) == 0 || polled[0].Events&POLLIN == 0 {
+			continue
+		}
+		msg, err := h.soc.RecvMessageBytes(DONTWAIT)
+		if err != nil {
+			if err != ErrEAGAIN {
+				h.error(err)
+				if isFatal(err) {
+					return
+				}
+			}
+			continue
+		}
+		expiry = time.Now().Add(h.timeout)
+		if len(msg) == 1 && bytes.Equal(msg[0], h.ping) {
+			continue
+		}
+		incoming = msg
+		receiving = true
+	}
+}
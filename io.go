@@ -0,0 +1,153 @@
+package zmq3
+
+import (
+	"io"
+)
+
+type reader struct {
+	soc *Socket
+	buf []byte
+}
+
+type writer struct {
+	soc *Socket
+}
+
+type frameReader struct {
+	soc     *Socket
+	buf     []byte
+	more    bool
+	started bool
+}
+
+/*
+Returns an io.Reader that reads message parts from the socket.
+
+Each message part received is returned by one or more calls to Read: if a
+message part is larger than the buffer, the remainder is buffered and
+returned by the next Read. Empty message parts are skipped, and message
+boundaries are not preserved.
+*/
+func (soc *Socket) Reader() io.Reader {
+	return &reader{soc: soc}
+}
+
+/*
+Returns an io.Reader that reads multi-part messages from the socket, with
+all parts of a message joined into one stream.
+
+Read returns io.EOF at the end of the last part of a message. After that,
+the next Read starts on the next message. This can be used to decode a
+message with a stream decoder, such as a json.Decoder, one message at a
+time.
+*/
+func (soc *Socket) FrameReader() io.Reader {
+	return &frameReader{soc: soc}
+}
+
+/*
+Returns an io.Writer that sends each Write as a single message part on the socket.
+*/
+func (soc *Socket) Writer() io.Writer {
+	return writer{soc: soc}
+}
+
+func (r *reader) Read(b []byte) (n int, err error) {
+	for len(r.buf) == 0 {
+		r.buf, err = r.soc.RecvBytes(0)
+		if err != nil {
+			return
+		}
+	}
+	n = copy(b, r.buf)
+	r.buf = r.buf[n:]
+	return
+}
+
+func (w writer) Write(b []byte) (n int, err error) {
+	n, err = w.soc.SendBytes(b, 0)
+	if err != nil {
+		n = 0
+	}
+	return
+}
+
+func (r *frameReader) Read(b []byte) (n int, err error) {
+	if len(b) == 0 {
+		return
+	}
+	for len(r.buf) == 0 {
+		if r.started && !r.more {
+			r.started = false
+			return 0, io.EOF
+		}
+		r.buf, err = r.soc.RecvBytes(0)
+		if err != nil {
+			return
+		}
+		r.started = true
+		r.more, err = r.soc.GetRcvmore()
+		if err != nil {
+			return
+		}
+	}
+	n = copy(b, r.buf)
+	r.buf = r.buf[n:]
+	return
+}
+
+/*
+Send all data from r as a multi-part message, in parts of chunkSize bytes.
+
+All parts but the last are chunkSize bytes, the last part may be shorter.
+If r has no data, a single empty part is sent. Use FrameReader() on the
+receiving side to read the message as a stream again.
+
+Returns the number of bytes sent. If reading fails, the message is ended
+with the part read before the error, so the socket is never left in the
+middle of a message, and the error is returned. The receiver then gets a
+truncated message. If sending fails, part of the message may have been
+sent.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-send#toc2
+*/
+func (soc *Socket) SendFrom(r io.Reader, chunkSize int, flags Flag) (int64, error) {
+	if chunkSize < 1 {
+		chunkSize = 8192
+	}
+	var total int64
+	buf := make([]byte, chunkSize)
+	next := make([]byte, chunkSize)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return total, err
+	}
+	for {
+		last := err != nil
+		m := 0
+		var readErr error
+		if !last {
+			// read ahead, to know if this is the last part
+			m, err = io.ReadFull(r, next)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				readErr = err
+				last = true
+			} else {
+				last = m == 0 && err == io.EOF
+			}
+		}
+		f := flags | SNDMORE
+		if last {
+			f = flags &^ SNDMORE
+		}
+		if _, e := soc.SendBytes(buf[:n], f); e != nil {
+			return total, e
+		}
+		total += int64(n)
+		if last {
+			return total, readErr
+		}
+		buf, next = next, buf
+		n = m
+	}
+}
@@ -0,0 +1,256 @@
+package zmq3
+
+/*
+#include <zmq.h>
+#include <stdint.h>
+extern void zmq3FreeCallback(uintptr_t hint);
+void zmq3_free_data(void *data, void *hint) {
+    zmq3FreeCallback((uintptr_t) hint);
+}
+int zmq3_msg_init_data(zmq_msg_t *msg, void *data, size_t size, uintptr_t hint) {
+    return zmq_msg_init_data(msg, data, size, zmq3_free_data, (void *) hint);
+}
+*/
+import "C"
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+/*
+A message part, with direct access to the data as received by 0MQ.
+
+Receiving with a Message avoids the copy made by (*Socket)RecvBytes().
+
+Example:
+
+    msg := zmq.NewMessage()
+    defer msg.Close()
+    for {
+        _, err := msg.Recv(socket, 0)
+        if err != nil {
+            break
+        }
+        //  Process msg.Data(), copying what you need to keep
+        if !msg.More() {
+            break
+        }
+    }
+*/
+type Message struct {
+	msg    C.zmq_msg_t
+	opened bool
+}
+
+/*
+Create an empty Message.
+
+If not closed explicitly, the message is closed on garbage collection.
+*/
+func NewMessage() *Message {
+	m := &Message{}
+	C.zmq_msg_init(&m.msg)
+	m.opened = true
+	runtime.SetFinalizer(m, (*Message).Close)
+	return m
+}
+
+/*
+Receive a message part from a socket into the Message, replacing its
+previous content.
+
+Returns the size of the message part.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-msg-recv#toc2
+*/
+func (m *Message) Recv(soc *Socket, flags Flag) (int, error) {
+	if !m.opened {
+		return -1, errMsgClosed
+	}
+	if soc.soc == nil {
+		return -1, ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
+	size, err := C.zmq_msg_recv(&m.msg, soc.soc, C.int(flags))
+	for size < 0 && soc.retry(err, flags) {
+		size, err = C.zmq_msg_recv(&m.msg, soc.soc, C.int(flags))
+	}
+	if size < 0 {
+		return int(size), errget(err)
+	}
+	return int(size), nil
+}
+
+/*
+The data of the message part.
+
+The returned slice is not a copy: it refers to memory owned by the Message.
+It is only valid until the next call to Recv() or Close(), and must not
+be modified.
+*/
+func (m *Message) Data() []byte {
+	if !m.opened {
+		return []byte{}
+	}
+	size := int(C.zmq_msg_size(&m.msg))
+	if size == 0 {
+		return []byte{}
+	}
+	return unsafe.Slice((*byte)(C.zmq_msg_data(&m.msg)), size)
+}
+
+// The size of the message part.
+func (m *Message) Size() int {
+	if !m.opened {
+		return 0
+	}
+	return int(C.zmq_msg_size(&m.msg))
+}
+
+// Are there more message parts to follow?
+func (m *Message) More() bool {
+	if !m.opened {
+		return false
+	}
+	return C.zmq_msg_more(&m.msg) != 0
+}
+
+/*
+Release the message part.
+
+After this, the slice returned by Data() can no longer be used.
+*/
+func (m *Message) Close() error {
+	if !m.opened {
+		return errMsgClosed
+	}
+	m.opened = false
+	if i, err := C.zmq_msg_close(&m.msg); i != 0 {
+		return errget(err)
+	}
+	return nil
+}
+
+type zeroCopyData struct {
+	pinner runtime.Pinner
+	free   func()
+}
+
+var (
+	zeroCopyMu   sync.Mutex
+	zeroCopyIdx  uintptr
+	zeroCopyBufs = make(map[uintptr]*zeroCopyData)
+)
+
+func zeroCopyRelease(id uintptr) {
+	zeroCopyMu.Lock()
+	z := zeroCopyBufs[id]
+	delete(zeroCopyBufs, id)
+	zeroCopyMu.Unlock()
+	if z == nil {
+		return
+	}
+	z.pinner.Unpin()
+	if z.free != nil {
+		go z.free()
+	}
+}
+
+/*
+Send a message part on a socket, without copying the data.
+
+The data is handed over to 0MQ: it must not be modified until 0MQ is done
+with it, which may be long after this function returns. Then free is
+called, in a separate goroutine. Free may be nil. If sending fails, free
+is called as well.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-send#toc2
+*/
+func (soc *Socket) SendZeroCopy(data []byte, free func(), flags Flag) (int, error) {
+	if soc.soc == nil {
+		if free != nil {
+			go free()
+		}
+		return -1, ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
+	if len(data) == 0 {
+		n, err := soc.SendBytes(data, flags)
+		if free != nil {
+			go free()
+		}
+		return n, err
+	}
+
+	z := &zeroCopyData{free: free}
+	z.pinner.Pin(&data[0])
+	zeroCopyMu.Lock()
+	zeroCopyIdx++
+	id := zeroCopyIdx
+	zeroCopyBufs[id] = z
+	zeroCopyMu.Unlock()
+
+	var msg C.zmq_msg_t
+	if i, err := C.zmq3_msg_init_data(&msg, unsafe.Pointer(&data[0]), C.size_t(len(data)), C.uintptr_t(id)); i != 0 {
+		zeroCopyRelease(id)
+		return -1, errget(err)
+	}
+	size, err := C.zmq_msg_send(&msg, soc.soc, C.int(flags))
+	for size < 0 && soc.retry(err, flags) {
+		size, err = C.zmq_msg_send(&msg, soc.soc, C.int(flags))
+	}
+	if size < 0 {
+		// this releases the data
+		C.zmq_msg_close(&msg)
+		return int(size), errget(err)
+	}
+	return int(size), nil
+}
+
+var recvCopyThreshold int64 = -1
+
+/*
+Set the size above which RecvAuto() doesn't copy a message part.
+
+Use a negative n to always copy, which is the default.
+*/
+func SetRecvCopyThreshold(n int) {
+	atomic.StoreInt64(&recvCopyThreshold, int64(n))
+}
+
+/*
+Receive a message part, copying it only if it is small.
+
+If the size of the message part is at most the threshold set with
+SetRecvCopyThreshold(), or if no threshold was set, the data is copied,
+as with RecvBytes(), and msg is nil.
+
+If the message part is larger, it is not copied. The data refers to
+memory owned by msg, and is only valid until msg is closed. The caller
+must keep msg, and call msg.Close() when done with the data: if msg is
+garbage collected, it is closed, and the data becomes invalid.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-msg-recv#toc2
+*/
+func (soc *Socket) RecvAuto(flags Flag) (data []byte, msg *Message, err error) {
+	m := NewMessage()
+	size, err := m.Recv(soc, flags)
+	if err != nil {
+		m.Close()
+		return []byte{}, nil, err
+	}
+	threshold := atomic.LoadInt64(&recvCopyThreshold)
+	if threshold < 0 || int64(size) <= threshold {
+		data = make([]byte, size)
+		copy(data, m.Data())
+		m.Close()
+		soc.tapped(data)
+		return data, nil, nil
+	}
+	data = m.Data()
+	soc.tapped(data)
+	return data, m, nil
+}
@@ -6,19 +6,24 @@ package zmq3
 import "C"
 
 import (
+	"errors"
 	"fmt"
 	"time"
 )
 
+var errNotPolled = errors.New("Socket is not in poller")
+
 // Return type for (*Poller)Poll
 type Polled struct {
-	Socket *Socket // socket with matched event(s)
+	Socket *Socket // socket with matched event(s), nil for a file descriptor
 	Events State   // actual matched event(s)
+	Fd     uintptr // file descriptor with matched event(s), if Socket is nil
 }
 
 type Poller struct {
 	items []C.zmq_pollitem_t
 	socks []*Socket
+	fds   []uintptr
 	size  int
 }
 
@@ -27,20 +32,93 @@ func NewPoller() *Poller {
 	return &Poller{
 		items: make([]C.zmq_pollitem_t, 0),
 		socks: make([]*Socket, 0),
+		fds:   make([]uintptr, 0),
 		size:  0}
 }
 
 // Add items to the poller
 //
 // Events is a bitwise OR of zmq.POLLIN and zmq.POLLOUT
-func (p *Poller) Add(soc *Socket, events State) {
+//
+// Returns the id of the item, which is the index of the item in the poller.
+func (p *Poller) Add(soc *Socket, events State) int {
 	var item C.zmq_pollitem_t
 	item.socket = soc.soc
 	item.fd = 0
 	item.events = C.short(events)
 	p.items = append(p.items, item)
 	p.socks = append(p.socks, soc)
+	p.fds = append(p.fds, 0)
+	p.size += 1
+	return p.size - 1
+}
+
+// Add a file descriptor to the poller, such as a pipe or a network socket
+// that is not a 0MQ socket
+//
+// On Windows, only sockets can be used.
+//
+// Events is a bitwise OR of zmq.POLLIN, zmq.POLLOUT and zmq.POLLERR
+//
+// Returns the id of the item, which is the index of the item in the poller.
+func (p *Poller) AddFd(fd uintptr, events State) int {
+	var item C.zmq_pollitem_t
+	item.socket = nil
+	setPollFd(&item, fd)
+	item.events = C.short(events)
+	p.items = append(p.items, item)
+	p.socks = append(p.socks, nil)
+	p.fds = append(p.fds, fd)
 	p.size += 1
+	return p.size - 1
+}
+
+// Remove a socket from the poller
+//
+// The ids of items added after this socket are decreased.
+//
+// Returns an error if the socket is not in the poller.
+func (p *Poller) Remove(soc *Socket) error {
+	found := false
+	items := make([]C.zmq_pollitem_t, 0, p.size)
+	socks := make([]*Socket, 0, p.size)
+	fds := make([]uintptr, 0, p.size)
+	for i, s := range p.socks {
+		if s == soc && s != nil {
+			found = true
+			continue
+		}
+		items = append(items, p.items[i])
+		socks = append(socks, s)
+		fds = append(fds, p.fds[i])
+	}
+	if !found {
+		return errNotPolled
+	}
+	p.items = items
+	p.socks = socks
+	p.fds = fds
+	p.size = len(items)
+	return nil
+}
+
+// Change the events to poll for on a socket
+//
+// Events is a bitwise OR of zmq.POLLIN and zmq.POLLOUT
+//
+// Returns an error if the socket is not in the poller.
+func (p *Poller) Update(soc *Socket, events State) error {
+	found := false
+	for i, s := range p.socks {
+		if s == soc && s != nil {
+			p.items[i].events = C.short(events)
+			found = true
+		}
+	}
+	if !found {
+		return errNotPolled
+	}
+	return nil
 }
 
 /*
@@ -48,8 +126,15 @@ Input/output multiplexing
 
 If timeout < 0, wait forever until a matching event is detected
 
+If timeout == 0, return immediately
+
+If the poll is interrupted by a signal (EINTR), it is restarted with the
+time left until the timeout.
+
 Only sockets with matching socket events are returned in the list.
 
+Returns ErrSocketClosed if a socket in the poller was closed.
+
 Example:
 
     poller := zmq.NewPoller()
@@ -79,23 +164,79 @@ func (p *Poller) Poll(timeout time.Duration) ([]Polled, error) {
 	if t < 0 {
 		t = -1
 	}
-	rv, err := C.zmq_poll(&p.items[0], C.int(len(p.items)), C.long(t))
-	if rv < 0 {
-		return lst, errget(err)
+	for _, soc := range p.socks {
+		if soc != nil && soc.soc == nil {
+			return lst, ErrSocketClosed
+		}
+	}
+	var items *C.zmq_pollitem_t
+	if p.size > 0 {
+		items = &p.items[0]
+	}
+	// poll(2) is not restarted after a signal, so restart with the time left
+	deadline := time.Now().Add(timeout)
+	for {
+		rv, err := C.zmq_poll(items, C.int(p.size), C.long(t))
+		if rv >= 0 {
+			break
+		}
+		if err = errget(err); err != ErrEINTR {
+			return lst, err
+		}
+		if timeout > 0 {
+			left := deadline.Sub(time.Now())
+			if left <= 0 {
+				return lst, nil
+			}
+			t = left / time.Millisecond
+		}
 	}
 	for i, it := range p.items {
 		if it.events&it.revents != 0 {
-			lst = append(lst, Polled{p.socks[i], State(it.revents)})
+			lst = append(lst, Polled{p.socks[i], State(it.revents), p.fds[i]})
 		}
 	}
 	return lst, nil
 }
 
+/*
+Input/output multiplexing, like Poll, but returns the matched events in a
+map with the socket as key.
+
+If no events are matched within the timeout, an empty map is returned.
+File descriptors added with AddFd are not included.
+
+Example:
+
+    ready, _ := poller.PollMap(time.Second)
+    if _, ok := ready[socket0]; ok {
+        msg, _ := socket0.Recv(0)
+        //  Process msg
+    }
+*/
+func (p *Poller) PollMap(timeout time.Duration) (map[*Socket]State, error) {
+	lst, err := p.Poll(timeout)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[*Socket]State, len(lst))
+	for _, polled := range lst {
+		if polled.Socket != nil {
+			m[polled.Socket] |= polled.Events
+		}
+	}
+	return m, nil
+}
+
 // Poller as string.
 func (p *Poller) String() string {
 	str := make([]string, 0)
 	for i, poll := range p.items {
-		str = append(str, fmt.Sprintf("%v%v", p.socks[i], State(poll.events)))
+		if p.socks[i] == nil {
+			str = append(str, fmt.Sprintf("Fd(%d)%v", p.fds[i], State(poll.events)))
+		} else {
+			str = append(str, fmt.Sprintf("%v%v", p.socks[i], State(poll.events)))
+		}
 	}
 	return fmt.Sprint("Poller", str)
 }
@@ -0,0 +1,12 @@
+// +build !windows
+
+package zmq3
+
+/*
+#include <zmq.h>
+*/
+import "C"
+
+func setPollFd(item *C.zmq_pollitem_t, fd uintptr) {
+	item.fd = C.int(fd)
+}
@@ -0,0 +1,168 @@
+package zmq3
+
+import (
+	"errors"
+	"sync"
+)
+
+var errPoolClosed = errors.New("Socket pool is closed")
+
+/*
+A pool of sockets of the same type, all connected to the same endpoint.
+
+This is useful for sockets like REQ, that can only handle one request at
+a time, in a program with many goroutines making requests. Each goroutine
+gets a socket for its own use, and returns it when done.
+
+Example:
+
+    pool := zmq.NewSocketPool(zmq.REQ, "tcp://localhost:5555", 10)
+    defer pool.Close()
+
+    soc, err := pool.Get()
+    if err != nil {
+        return err
+    }
+    soc.Send("request", 0)
+    reply, err := soc.Recv(0)
+    if err != nil {
+        //  A REQ socket without a reply is unusable, don't reuse it
+        soc.Close()
+    }
+    pool.Put(soc)
+*/
+type SocketPool struct {
+	ctx      *Context
+	t        Type
+	endpoint string
+	tokens   chan bool
+	done     chan bool
+	mu       sync.Mutex
+	idle     []*Socket
+	closed   bool
+}
+
+/*
+Create a pool of sockets in the default context.
+
+Sockets are created when needed, and connected to endpoint. If max is
+positive, at most max sockets are in use at the same time, and Get()
+blocks until a socket is returned to the pool.
+*/
+func NewSocketPool(t Type, endpoint string, max int) *SocketPool {
+	return defaultCtx.NewSocketPool(t, endpoint, max)
+}
+
+/*
+Create a pool of sockets in the given context.
+
+See: func NewSocketPool
+*/
+func (ctx *Context) NewSocketPool(t Type, endpoint string, max int) *SocketPool {
+	p := &SocketPool{
+		ctx:      ctx,
+		t:        t,
+		endpoint: endpoint,
+		done:     make(chan bool),
+		idle:     make([]*Socket, 0),
+	}
+	if max > 0 {
+		p.tokens = make(chan bool, max)
+	}
+	return p
+}
+
+/*
+Get a socket from the pool.
+
+Returns an idle socket if there is one, or else creates a new one. The
+socket must be returned with Put() when done, also if it was closed.
+*/
+func (p *SocketPool) Get() (*Socket, error) {
+	if p.tokens != nil {
+		select {
+		case p.tokens <- true:
+		case <-p.done:
+			return nil, errPoolClosed
+		}
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		p.release()
+		return nil, errPoolClosed
+	}
+	if n := len(p.idle); n > 0 {
+		soc := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return soc, nil
+	}
+	p.mu.Unlock()
+
+	soc, err := p.ctx.NewSocket(p.t)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+	if err := soc.Connect(p.endpoint); err != nil {
+		soc.Close()
+		p.release()
+		return nil, err
+	}
+	return soc, nil
+}
+
+/*
+Return a socket to the pool.
+
+A socket that was closed is discarded. After the pool is closed, sockets
+returned are closed.
+*/
+func (p *SocketPool) Put(soc *Socket) {
+	defer p.release()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if soc.soc == nil {
+		return
+	}
+	if p.closed {
+		soc.Close()
+		return
+	}
+	p.idle = append(p.idle, soc)
+}
+
+func (p *SocketPool) release() {
+	if p.tokens != nil {
+		<-p.tokens
+	}
+}
+
+/*
+Close the pool, and all idle sockets in it.
+
+Sockets that are in use are closed when they are returned with Put().
+Calls to Get() return an error after this.
+*/
+func (p *SocketPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	close(p.done)
+	var err error
+	for _, soc := range idle {
+		if e := soc.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -1,6 +1,7 @@
 package zmq3
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -58,15 +59,20 @@ func (r *Reactor) AddSocket(soc *Socket, events State, handler func(State) error
 	r.p.Add(soc, events)
 }
 
+// Add socket handler to the reactor, with a handler that also receives the socket.
+//
+// See: func (*Reactor) AddSocket
+func (r *Reactor) AddSocketHandler(soc *Socket, events State, handler func(*Socket, State) error) {
+	r.AddSocket(soc, events, func(state State) error {
+		return handler(soc, state)
+	})
+}
+
 // Remove a socket handler from the reactor.
 func (r *Reactor) RemoveSocket(soc *Socket) {
 	if _, ok := r.sockets[soc]; ok {
 		delete(r.sockets, soc)
-		// rebuild poller
-		r.p = NewPoller()
-		for s, props := range r.sockets {
-			r.p.Add(s, props.e)
-		}
+		r.p.Remove(soc)
 	}
 }
 
@@ -124,7 +130,26 @@ func (r *Reactor) SetVerbose(verbose bool) {
 //
 // The run exits when any handler returns an error, returning that same error.
 func (r *Reactor) Run(interval time.Duration) (err error) {
+	return r.run(context.Background(), interval)
+}
+
+// Run the reactor until the context is done, or until a handler returns an error.
+//
+// Sockets are polled with a time-out of 100 milliseconds, so cancellation is
+// noticed at least that often, but not while a handler is running.
+//
+// Returns ctx.Err() when the context is done, or else the error, as with Run().
+func (r *Reactor) RunContext(ctx context.Context) error {
+	return r.run(ctx, contextPollInterval)
+}
+
+func (r *Reactor) run(ctx context.Context, interval time.Duration) (err error) {
 	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
 
 		// process requests to remove channels
 		for _, id := range r.remove {
@@ -0,0 +1,82 @@
+package zmq3
+
+/*
+#include <zmq.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+/*
+Send a request on a REQ socket, and wait for the reply, retrying if no
+reply arrives in time.
+
+This is the Lazy Pirate pattern, see: http://zguide.zeromq.org/page:all#toc89
+
+A REQ socket that doesn't get a reply can't be used to send another
+request. So after each timeout, the socket is closed and replaced by a
+new socket of the same type, connected to the same endpoints. Socket
+options set earlier are lost, except for the defaults of the context, set
+with SetDefaultSockOpt(), and pollers that have the socket need to be
+rebuilt. The request is sent at most retries+1 times.
+
+Returns the reply, or an error if no reply arrived after all retries.
+*/
+func (soc *Socket) Request(payload [][]byte, timeout time.Duration, retries int) ([][]byte, error) {
+	for try := 0; ; try++ {
+		if _, err := soc.SendMessage(payload); err != nil {
+			return [][]byte{}, err
+		}
+		poller := NewPoller()
+		poller.Add(soc, POLLIN)
+		polled, err := poller.Poll(timeout)
+		if err != nil {
+			return [][]byte{}, err
+		}
+		if len(polled) > 0 {
+			return soc.RecvMessageBytes(0)
+		}
+		if err := soc.reopen(); err != nil {
+			return [][]byte{}, err
+		}
+		if try >= retries {
+			return [][]byte{}, fmt.Errorf("No reply after %d attempts", try+1)
+		}
+	}
+}
+
+// Replace the socket by a new one of the same type, connected to the same endpoints.
+func (soc *Socket) reopen() error {
+	if soc.soc == nil {
+		return ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
+	t, err := soc.GetType()
+	if err != nil {
+		return err
+	}
+	soc.SetLinger(0)
+	C.zmq_close(soc.soc)
+	s, e := C.zmq_socket(soc.ctx.ctx, C.int(t))
+	if s == nil {
+		soc.soc = nil
+		soc.ctx.removeSocket(soc)
+		return errget(e)
+	}
+	soc.soc = s
+	if err := soc.ctx.initSocket(soc); err != nil {
+		return err
+	}
+	endpoints := soc.connected
+	soc.connected = nil
+	for _, endpoint := range endpoints {
+		if err := soc.Connect(endpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
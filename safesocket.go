@@ -0,0 +1,776 @@
+package zmq3
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+/*
+A Socket that can be used from multiple goroutines.
+
+Each call on a SafeSocket locks a mutex around the call on the underlying
+Socket. This serializes access, it doesn't make it parallel: a blocking
+call, such as Recv() waiting for a message, holds the lock and blocks all
+other calls on the SafeSocket, including Send(). Calls are slower than on
+a plain Socket, because of the locking. If you can, use a Socket in a
+single goroutine instead.
+
+Note that each call is locked separately. A Send() with SNDMORE from one
+goroutine can still be interleaved with a Send() from another goroutine.
+Use SendMessage() to send a multi-part message in a single call.
+
+Methods of Socket that hand the socket to a goroutine or to another
+object, such as SendChannel(), Reader(), AsConn(), Tap() or Serve(), have
+no counterpart on a SafeSocket, because the lock can't cover their use.
+*/
+type SafeSocket struct {
+	soc *Socket
+	mu  sync.Mutex
+}
+
+// Create a SafeSocket in the default context.
+func NewSafeSocket(t Type) (*SafeSocket, error) {
+	soc, err := NewSocket(t)
+	if err != nil {
+		return nil, err
+	}
+	return &SafeSocket{soc: soc}, nil
+}
+
+// Create a SafeSocket in the given context.
+func (ctx *Context) NewSafeSocket(t Type) (*SafeSocket, error) {
+	soc, err := ctx.NewSocket(t)
+	if err != nil {
+		return nil, err
+	}
+	return &SafeSocket{soc: soc}, nil
+}
+
+// SafeSocket as string.
+func (s *SafeSocket) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.String()
+}
+
+// See: func (*Socket) Bind
+func (s *SafeSocket) Bind(endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.Bind(endpoint)
+}
+
+// See: func (*Socket) BindAll
+func (s *SafeSocket) BindAll(endpoints ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.BindAll(endpoints...)
+}
+
+// See: func (*Socket) BindInfo
+func (s *SafeSocket) BindInfo(endpoint string) (EndpointInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.BindInfo(endpoint)
+}
+
+// See: func (*Socket) BindTCP
+func (s *SafeSocket) BindTCP(addr string, port int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.BindTCP(addr, port)
+}
+
+// The clone is a SafeSocket as well.
+//
+// See: func (*Socket) Clone
+func (s *SafeSocket) Clone() (*SafeSocket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, err := s.soc.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return &SafeSocket{soc: c}, nil
+}
+
+// See: func (*Socket) Close
+func (s *SafeSocket) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.Close()
+}
+
+// See: func (*Socket) Connect
+func (s *SafeSocket) Connect(endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.Connect(endpoint)
+}
+
+// See: func (*Socket) ConnectAll
+func (s *SafeSocket) ConnectAll(endpoints ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.ConnectAll(endpoints...)
+}
+
+// See: func (*Socket) ConnectWait
+func (s *SafeSocket) ConnectWait(endpoint string, timeout time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.ConnectWait(endpoint, timeout)
+}
+
+// See: func (*Socket) Disconnect
+func (s *SafeSocket) Disconnect(endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.Disconnect(endpoint)
+}
+
+// See: func (*Socket) Drain
+func (s *SafeSocket) Drain() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.Drain()
+}
+
+// See: func (*Socket) GetAffinity
+func (s *SafeSocket) GetAffinity() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetAffinity()
+}
+
+// See: func (*Socket) GetBacklog
+func (s *SafeSocket) GetBacklog() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetBacklog()
+}
+
+// See: func (*Socket) GetDelayAttachOnConnect
+func (s *SafeSocket) GetDelayAttachOnConnect() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetDelayAttachOnConnect()
+}
+
+// See: func (*Socket) GetEvents
+func (s *SafeSocket) GetEvents() (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetEvents()
+}
+
+// See: func (*Socket) GetIdentity
+func (s *SafeSocket) GetIdentity() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetIdentity()
+}
+
+// See: func (*Socket) GetIdentityHex
+func (s *SafeSocket) GetIdentityHex() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetIdentityHex()
+}
+
+// See: func (*Socket) GetImmediate
+func (s *SafeSocket) GetImmediate() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetImmediate()
+}
+
+// See: func (*Socket) GetIpv4only
+func (s *SafeSocket) GetIpv4only() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetIpv4only()
+}
+
+// See: func (*Socket) GetLastEndpoint
+func (s *SafeSocket) GetLastEndpoint() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetLastEndpoint()
+}
+
+// See: func (*Socket) GetLinger
+func (s *SafeSocket) GetLinger() (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetLinger()
+}
+
+// See: func (*Socket) GetMaxmsgsize
+func (s *SafeSocket) GetMaxmsgsize() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetMaxmsgsize()
+}
+
+// See: func (*Socket) GetMulticastHops
+func (s *SafeSocket) GetMulticastHops() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetMulticastHops()
+}
+
+// See: func (*Socket) GetRate
+func (s *SafeSocket) GetRate() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetRate()
+}
+
+// See: func (*Socket) GetRcvbuf
+func (s *SafeSocket) GetRcvbuf() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetRcvbuf()
+}
+
+// See: func (*Socket) GetRcvhwm
+func (s *SafeSocket) GetRcvhwm() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetRcvhwm()
+}
+
+// See: func (*Socket) GetRcvmore
+func (s *SafeSocket) GetRcvmore() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetRcvmore()
+}
+
+// See: func (*Socket) GetRcvtimeo
+func (s *SafeSocket) GetRcvtimeo() (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetRcvtimeo()
+}
+
+// See: func (*Socket) GetReconnectIvl
+func (s *SafeSocket) GetReconnectIvl() (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetReconnectIvl()
+}
+
+// See: func (*Socket) GetReconnectIvlMax
+func (s *SafeSocket) GetReconnectIvlMax() (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetReconnectIvlMax()
+}
+
+// See: func (*Socket) GetRecoveryIvl
+func (s *SafeSocket) GetRecoveryIvl() (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetRecoveryIvl()
+}
+
+// See: func (*Socket) GetSndbuf
+func (s *SafeSocket) GetSndbuf() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetSndbuf()
+}
+
+// See: func (*Socket) GetSndhwm
+func (s *SafeSocket) GetSndhwm() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetSndhwm()
+}
+
+// See: func (*Socket) GetSndtimeo
+func (s *SafeSocket) GetSndtimeo() (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetSndtimeo()
+}
+
+// See: func (*Socket) GetSockOptInt
+func (s *SafeSocket) GetSockOptInt(opt int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetSockOptInt(opt)
+}
+
+// See: func (*Socket) GetSockOptString
+func (s *SafeSocket) GetSockOptString(opt int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetSockOptString(opt)
+}
+
+// See: func (*Socket) GetTcpKeepalive
+func (s *SafeSocket) GetTcpKeepalive() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetTcpKeepalive()
+}
+
+// See: func (*Socket) GetTcpKeepaliveCnt
+func (s *SafeSocket) GetTcpKeepaliveCnt() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetTcpKeepaliveCnt()
+}
+
+// See: func (*Socket) GetTcpKeepaliveIdle
+func (s *SafeSocket) GetTcpKeepaliveIdle() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetTcpKeepaliveIdle()
+}
+
+// See: func (*Socket) GetTcpKeepaliveIntvl
+func (s *SafeSocket) GetTcpKeepaliveIntvl() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetTcpKeepaliveIntvl()
+}
+
+// See: func (*Socket) GetType
+func (s *SafeSocket) GetType() (Type, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetType()
+}
+
+// See: func (*Socket) Monitor
+func (s *SafeSocket) Monitor(addr string, events Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.Monitor(addr, events)
+}
+
+// See: func (*Socket) Recv
+func (s *SafeSocket) Recv(flags Flag) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.Recv(flags)
+}
+
+// See: func (*Socket) RecvAuto
+func (s *SafeSocket) RecvAuto(flags Flag) (data []byte, msg *Message, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvAuto(flags)
+}
+
+// See: func (*Socket) RecvBytes
+func (s *SafeSocket) RecvBytes(flags Flag) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvBytes(flags)
+}
+
+// See: func (*Socket) RecvContext
+func (s *SafeSocket) RecvContext(ctx context.Context, flags Flag) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvContext(ctx, flags)
+}
+
+// See: func (*Socket) RecvEvent
+func (s *SafeSocket) RecvEvent(flags Flag) (event_type Event, addr string, value int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvEvent(flags)
+}
+
+// See: func (*Socket) RecvGob
+func (s *SafeSocket) RecvGob(v interface{}, flags Flag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvGob(v, flags)
+}
+
+// See: func (*Socket) RecvInto
+func (s *SafeSocket) RecvInto(buf []byte, flags Flag) (n int, truncated bool, more bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvInto(buf, flags)
+}
+
+// See: func (*Socket) RecvJSON
+func (s *SafeSocket) RecvJSON(v interface{}, flags Flag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvJSON(v, flags)
+}
+
+// See: func (*Socket) RecvMessage
+func (s *SafeSocket) RecvMessage(flags Flag) (msg []string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvMessage(flags)
+}
+
+// See: func (*Socket) RecvMessageBytes
+func (s *SafeSocket) RecvMessageBytes(flags Flag) (msg [][]byte, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvMessageBytes(flags)
+}
+
+// See: func (*Socket) RecvMessageCapped
+func (s *SafeSocket) RecvMessageCapped(maxParts int, maxTotalBytes int) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvMessageCapped(maxParts, maxTotalBytes)
+}
+
+// See: func (*Socket) RecvMore
+func (s *SafeSocket) RecvMore() (data []byte, hasMore bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvMore()
+}
+
+// See: func (*Socket) RecvTimeout
+func (s *SafeSocket) RecvTimeout(d time.Duration, flags Flag) (data []byte, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.RecvTimeout(d, flags)
+}
+
+// See: func (*Socket) ReplyError
+func (s *SafeSocket) ReplyError(msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.ReplyError(msg)
+}
+
+// See: func (*Socket) Request
+func (s *SafeSocket) Request(payload [][]byte, timeout time.Duration, retries int) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.Request(payload, timeout, retries)
+}
+
+// See: func (*Socket) Send
+func (s *SafeSocket) Send(data string, flags Flag) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.Send(data, flags)
+}
+
+// See: func (*Socket) SendBatch
+func (s *SafeSocket) SendBatch(frames [][]byte, flags Flag) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendBatch(frames, flags)
+}
+
+// See: func (*Socket) SendBytes
+func (s *SafeSocket) SendBytes(data []byte, flags Flag) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendBytes(data, flags)
+}
+
+// See: func (*Socket) SendContext
+func (s *SafeSocket) SendContext(ctx context.Context, data []byte, flags Flag) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendContext(ctx, data, flags)
+}
+
+// See: func (*Socket) SendCounted
+func (s *SafeSocket) SendCounted(data []byte, flags Flag) (sent int, dropped bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendCounted(data, flags)
+}
+
+// See: func (*Socket) SendFrom
+func (s *SafeSocket) SendFrom(r io.Reader, chunkSize int, flags Flag) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendFrom(r, chunkSize, flags)
+}
+
+// See: func (*Socket) SendGob
+func (s *SafeSocket) SendGob(v interface{}, flags Flag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendGob(v, flags)
+}
+
+// See: func (*Socket) SendJSON
+func (s *SafeSocket) SendJSON(v interface{}, flags Flag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendJSON(v, flags)
+}
+
+// See: func (*Socket) SendLast
+func (s *SafeSocket) SendLast(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendLast(data)
+}
+
+// See: func (*Socket) SendMessage
+func (s *SafeSocket) SendMessage(parts ...interface{}) (total int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendMessage(parts...)
+}
+
+// See: func (*Socket) SendMessageDontwait
+func (s *SafeSocket) SendMessageDontwait(parts ...interface{}) (total int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendMessageDontwait(parts...)
+}
+
+// See: func (*Socket) SendMore
+func (s *SafeSocket) SendMore(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendMore(data)
+}
+
+// See: func (*Socket) SendZeroCopy
+func (s *SafeSocket) SendZeroCopy(data []byte, free func(), flags Flag) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SendZeroCopy(data, free, flags)
+}
+
+// See: func (*Socket) SetAffinity
+func (s *SafeSocket) SetAffinity(value uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetAffinity(value)
+}
+
+// See: func (*Socket) SetBacklog
+func (s *SafeSocket) SetBacklog(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetBacklog(value)
+}
+
+// See: func (*Socket) SetDelayAttachOnConnect
+func (s *SafeSocket) SetDelayAttachOnConnect(value bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetDelayAttachOnConnect(value)
+}
+
+// See: func (*Socket) SetIdentity
+func (s *SafeSocket) SetIdentity(value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetIdentity(value)
+}
+
+// See: func (*Socket) SetImmediate
+func (s *SafeSocket) SetImmediate(value bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetImmediate(value)
+}
+
+// See: func (*Socket) SetIpv4only
+func (s *SafeSocket) SetIpv4only(value bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetIpv4only(value)
+}
+
+// See: func (*Socket) SetLinger
+func (s *SafeSocket) SetLinger(value time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetLinger(value)
+}
+
+// See: func (*Socket) SetMaxmsgsize
+func (s *SafeSocket) SetMaxmsgsize(value int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetMaxmsgsize(value)
+}
+
+// See: func (*Socket) SetMulticastHops
+func (s *SafeSocket) SetMulticastHops(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetMulticastHops(value)
+}
+
+// See: func (*Socket) SetRate
+func (s *SafeSocket) SetRate(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetRate(value)
+}
+
+// See: func (*Socket) SetRcvbuf
+func (s *SafeSocket) SetRcvbuf(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetRcvbuf(value)
+}
+
+// See: func (*Socket) SetRcvhwm
+func (s *SafeSocket) SetRcvhwm(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetRcvhwm(value)
+}
+
+// See: func (*Socket) SetRcvtimeo
+func (s *SafeSocket) SetRcvtimeo(value time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetRcvtimeo(value)
+}
+
+// See: func (*Socket) SetReconnectIvl
+func (s *SafeSocket) SetReconnectIvl(value time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetReconnectIvl(value)
+}
+
+// See: func (*Socket) SetReconnectIvlMax
+func (s *SafeSocket) SetReconnectIvlMax(value time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetReconnectIvlMax(value)
+}
+
+// See: func (*Socket) SetRecoveryIvl
+func (s *SafeSocket) SetRecoveryIvl(value time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetRecoveryIvl(value)
+}
+
+// See: func (*Socket) SetRouterMandatory
+func (s *SafeSocket) SetRouterMandatory(value bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetRouterMandatory(value)
+}
+
+// See: func (*Socket) SetSndbuf
+func (s *SafeSocket) SetSndbuf(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetSndbuf(value)
+}
+
+// See: func (*Socket) SetSndhwm
+func (s *SafeSocket) SetSndhwm(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetSndhwm(value)
+}
+
+// See: func (*Socket) SetSndtimeo
+func (s *SafeSocket) SetSndtimeo(value time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetSndtimeo(value)
+}
+
+// See: func (*Socket) SetSockOptInt
+func (s *SafeSocket) SetSockOptInt(opt int, value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetSockOptInt(opt, value)
+}
+
+// See: func (*Socket) SetSockOptString
+func (s *SafeSocket) SetSockOptString(opt int, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetSockOptString(opt, value)
+}
+
+// See: func (*Socket) SetSubscribe
+func (s *SafeSocket) SetSubscribe(filter string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetSubscribe(filter)
+}
+
+// See: func (*Socket) SetTcpAcceptFilter
+func (s *SafeSocket) SetTcpAcceptFilter(filter string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetTcpAcceptFilter(filter)
+}
+
+// See: func (*Socket) SetTcpKeepalive
+func (s *SafeSocket) SetTcpKeepalive(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetTcpKeepalive(value)
+}
+
+// See: func (*Socket) SetTcpKeepaliveCnt
+func (s *SafeSocket) SetTcpKeepaliveCnt(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetTcpKeepaliveCnt(value)
+}
+
+// See: func (*Socket) SetTcpKeepaliveIdle
+func (s *SafeSocket) SetTcpKeepaliveIdle(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetTcpKeepaliveIdle(value)
+}
+
+// See: func (*Socket) SetTcpKeepaliveIntvl
+func (s *SafeSocket) SetTcpKeepaliveIntvl(value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetTcpKeepaliveIntvl(value)
+}
+
+// See: func (*Socket) SetUnsubscribe
+func (s *SafeSocket) SetUnsubscribe(filter string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetUnsubscribe(filter)
+}
+
+// See: func (*Socket) SetXpubVerbose
+func (s *SafeSocket) SetXpubVerbose(value bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.SetXpubVerbose(value)
+}
+
+// See: func (*Socket) Unbind
+func (s *SafeSocket) Unbind(endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.Unbind(endpoint)
+}
+
+// See: func (*Socket) WaitForConnect
+func (s *SafeSocket) WaitForConnect(endpoint string, timeout time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.WaitForConnect(endpoint, timeout)
+}
@@ -0,0 +1,10 @@
+// +build !windows
+
+package zmq3
+
+// See: func (*Socket) GetFd
+func (s *SafeSocket) GetFd() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soc.GetFd()
+}
@@ -0,0 +1,75 @@
+package zmq3
+
+import (
+	"context"
+)
+
+/*
+Send an error message as the reply on a REP socket.
+
+A REP socket must send a reply to each request it received, before it
+can receive the next request. If a request can't be handled, use this to
+send a reply anyway. The reply is a single message part with msg.
+*/
+func (soc *Socket) ReplyError(msg string) error {
+	_, err := soc.Send(msg, 0)
+	return err
+}
+
+/*
+Handle requests on a REP socket, until the context is cancelled.
+
+For each request, the handler is called with all parts of the request,
+and the parts it returns are sent as the reply. If the handler returns an
+error, the error message is sent as the reply, with ReplyError().
+
+Serve returns ctx.Err() when the context is done, or the error if
+receiving or sending fails, for instance ETERM when the 0MQ context is
+terminated. The context is checked while waiting for a request, at least
+every 100 milliseconds, not while the handler is running.
+
+Example:
+
+    err := rep.Serve(ctx, func(request [][]byte) ([][]byte, error) {
+        return [][]byte{[]byte("World")}, nil
+    })
+*/
+func (soc *Socket) Serve(ctx context.Context, handler func(request [][]byte) (reply [][]byte, err error)) error {
+	for {
+		request, err := soc.recvMessageContext(ctx)
+		if err != nil {
+			return err
+		}
+		reply, err := handler(request)
+		if err != nil {
+			err = soc.ReplyError(err.Error())
+		} else {
+			_, err = soc.SendMessage(reply)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Receive all parts of a message, waiting for the first part until the context is done.
+func (soc *Socket) recvMessageContext(ctx context.Context) ([][]byte, error) {
+	b, err := soc.RecvContext(ctx, 0)
+	if err != nil {
+		return [][]byte{}, err
+	}
+	msg := [][]byte{b}
+	for {
+		more, err := soc.GetRcvmore()
+		if err != nil {
+			return [][]byte{}, err
+		}
+		if !more {
+			return msg, nil
+		}
+		if b, err = soc.RecvBytes(0); err != nil {
+			return [][]byte{}, err
+		}
+		msg = append(msg, b)
+	}
+}
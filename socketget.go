@@ -7,11 +7,18 @@ package zmq3
 import "C"
 
 import (
+	"encoding/hex"
+	"runtime"
+	"strings"
 	"time"
 	"unsafe"
 )
 
 func (soc *Socket) getString(opt C.int, bufsize int) (string, error) {
+	if soc.soc == nil {
+		return "", ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	value := make([]byte, bufsize)
 	size := C.size_t(bufsize)
 	if i, err := C.zmq_getsockopt(soc.soc, opt, unsafe.Pointer(&value[0]), &size); i != 0 {
@@ -21,6 +28,10 @@ func (soc *Socket) getString(opt C.int, bufsize int) (string, error) {
 }
 
 func (soc *Socket) getInt(opt C.int) (int, error) {
+	if soc.soc == nil {
+		return 0, ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	value := C.int(0)
 	size := C.size_t(unsafe.Sizeof(value))
 	if i, err := C.zmq_getsockopt(soc.soc, opt, unsafe.Pointer(&value), &size); i != 0 {
@@ -30,6 +41,10 @@ func (soc *Socket) getInt(opt C.int) (int, error) {
 }
 
 func (soc *Socket) getInt64(opt C.int) (int64, error) {
+	if soc.soc == nil {
+		return 0, ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	value := C.int64_t(0)
 	size := C.size_t(unsafe.Sizeof(value))
 	if i, err := C.zmq_getsockopt(soc.soc, opt, unsafe.Pointer(&value), &size); i != 0 {
@@ -39,6 +54,10 @@ func (soc *Socket) getInt64(opt C.int) (int64, error) {
 }
 
 func (soc *Socket) getUInt64(opt C.int) (uint64, error) {
+	if soc.soc == nil {
+		return 0, ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	value := C.uint64_t(0)
 	size := C.size_t(unsafe.Sizeof(value))
 	if i, err := C.zmq_getsockopt(soc.soc, opt, unsafe.Pointer(&value), &size); i != 0 {
@@ -47,6 +66,29 @@ func (soc *Socket) getUInt64(opt C.int) (uint64, error) {
 	return uint64(value), nil
 }
 
+/*
+Get an integer socket option by its number, for options that don't have
+their own function.
+
+For the options, see: http://api.zeromq.org/3-2:zmq-getsockopt
+*/
+func (soc *Socket) GetSockOptInt(opt int) (int, error) {
+	return soc.getInt(C.int(opt))
+}
+
+/*
+Get a string socket option by its number, for options that don't have
+their own function.
+
+The value is returned as given by 0MQ: for options that are C strings,
+such as OPT_LAST_ENDPOINT, this includes the terminating zero byte.
+
+For the options, see: http://api.zeromq.org/3-2:zmq-getsockopt
+*/
+func (soc *Socket) GetSockOptString(opt int) (string, error) {
+	return soc.getString(C.int(opt), 1024)
+}
+
 // ZMQ_TYPE: Retrieve socket type
 //
 // See: http://api.zeromq.org/3-2:zmq-getsockopt#toc3
@@ -57,6 +99,8 @@ func (soc *Socket) GetType() (Type, error) {
 
 // ZMQ_RCVMORE: More message data parts to follow
 //
+// Reports on the last message part received with Recv() or RecvBytes().
+//
 // See: http://api.zeromq.org/3-2:zmq-getsockopt#toc4
 func (soc *Socket) GetRcvmore() (bool, error) {
 	v, err := soc.getInt(C.ZMQ_RCVMORE)
@@ -84,13 +128,24 @@ func (soc *Socket) GetAffinity() (uint64, error) {
 	return soc.getUInt64(C.ZMQ_AFFINITY)
 }
 
-// ZMQ_IDENTITY: Set socket identity
+// ZMQ_IDENTITY: Retrieve socket identity
+//
+// Returns an empty string if no identity was set.
 //
 // See: http://api.zeromq.org/3-2:zmq-getsockopt#toc8
 func (soc *Socket) GetIdentity() (string, error) {
 	return soc.getString(C.ZMQ_IDENTITY, 256)
 }
 
+// ZMQ_IDENTITY: Retrieve socket identity, in hexadecimal
+//
+// Identities are often binary, this gives a printable form, for logging.
+// Returns an empty string if no identity was set.
+func (soc *Socket) GetIdentityHex() (string, error) {
+	id, err := soc.GetIdentity()
+	return hex.EncodeToString([]byte(id)), err
+}
+
 // ZMQ_RATE: Retrieve multicast data rate
 //
 // See: http://api.zeromq.org/3-2:zmq-getsockopt#toc9
@@ -217,6 +272,15 @@ func (soc *Socket) GetDelayAttachOnConnect() (bool, error) {
 	return v != 0, err
 }
 
+// ZMQ_IMMEDIATE: Retrieve attach-on-connect value
+//
+// This is the same option as ZMQ_DELAY_ATTACH_ON_CONNECT, under the name
+// it has since ZeroMQ 3.3. See: func (*Socket) GetDelayAttachOnConnect
+func (soc *Socket) GetImmediate() (bool, error) {
+	v, err := soc.getInt(C.int(OPT_IMMEDIATE))
+	return v != 0, err
+}
+
 // ZMQ_FD: Retrieve file descriptor associated with the socket
 // see socketget_unix.go and socketget_windows.go
 
@@ -224,15 +288,22 @@ func (soc *Socket) GetDelayAttachOnConnect() (bool, error) {
 //
 // See: http://api.zeromq.org/3-2:zmq-getsockopt#toc24
 func (soc *Socket) GetEvents() (State, error) {
-	v, err :=  soc.getInt(C.ZMQ_EVENTS)
+	v, err := soc.getInt(C.ZMQ_EVENTS)
 	return State(v), err
 }
 
 // ZMQ_LAST_ENDPOINT: Retrieve the last endpoint set
 //
+// After binding to a wildcard address, this returns the actual endpoint,
+// for instance "tcp://127.0.0.1:54321".
+//
 // See: http://api.zeromq.org/3-2:zmq-getsockopt#toc25
 func (soc *Socket) GetLastEndpoint() (string, error) {
-	return soc.getString(C.ZMQ_LAST_ENDPOINT, 1024)
+	s, err := soc.getString(C.ZMQ_LAST_ENDPOINT, 1024)
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return s, err
 }
 
 // ZMQ_TCP_KEEPALIVE: Override SO_KEEPALIVE socket option
@@ -9,6 +9,11 @@ import "C"
 
 // ZMQ_FD: Retrieve file descriptor associated with the socket
 //
+// The file descriptor signals, edge-triggered, that the state of the socket
+// may have changed. It does not signal that a message is ready to be read.
+// After it signals, always use GetEvents() to retrieve the actual state,
+// and handle all pending events before waiting on the descriptor again.
+//
 // See: http://api.zeromq.org/3-2:zmq-getsockopt#toc23
 func (soc *Socket) GetFd() (int, error) {
 	return soc.getInt(C.ZMQ_FD)
@@ -1,3 +1,4 @@
+//go:build windows
 // +build windows
 
 package zmq3
@@ -8,15 +9,25 @@ package zmq3
 import "C"
 
 import (
+	"runtime"
 	"unsafe"
 )
 
 /*
 ZMQ_FD: Retrieve file descriptor associated with the socket
 
+The file descriptor signals, edge-triggered, that the state of the socket
+may have changed. It does not signal that a message is ready to be read.
+After it signals, always use GetEvents() to retrieve the actual state,
+and handle all pending events before waiting on the descriptor again.
+
 See: http://api.zeromq.org/3-2:zmq-getsockopt#toc23
 */
 func (soc *Socket) GetFd() (uintptr, error) {
+	if soc.soc == nil {
+		return uintptr(0), ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	value := C.SOCKET(0)
 	size := C.size_t(unsafe.Sizeof(value))
 	if i, err := C.zmq_getsockopt(soc.soc, C.ZMQ_FD, unsafe.Pointer(&value), &size); i != 0 {
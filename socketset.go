@@ -8,11 +8,17 @@ package zmq3
 import "C"
 
 import (
+	"errors"
+	"runtime"
 	"time"
 	"unsafe"
 )
 
 func (soc *Socket) setString(opt C.int, s string) error {
+	if soc.soc == nil {
+		return ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	cs := C.CString(s)
 	defer C.free(unsafe.Pointer(cs))
 	if i, err := C.zmq_setsockopt(soc.soc, opt, unsafe.Pointer(cs), C.size_t(len(s))); i != 0 {
@@ -22,6 +28,10 @@ func (soc *Socket) setString(opt C.int, s string) error {
 }
 
 func (soc *Socket) setInt(opt C.int, value int) error {
+	if soc.soc == nil {
+		return ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	val := C.int(value)
 	if i, err := C.zmq_setsockopt(soc.soc, opt, unsafe.Pointer(&val), C.size_t(unsafe.Sizeof(val))); i != 0 {
 		return errget(err)
@@ -30,6 +40,10 @@ func (soc *Socket) setInt(opt C.int, value int) error {
 }
 
 func (soc *Socket) setInt64(opt C.int, value int64) error {
+	if soc.soc == nil {
+		return ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	val := C.int64_t(value)
 	if i, err := C.zmq_setsockopt(soc.soc, opt, unsafe.Pointer(&val), C.size_t(unsafe.Sizeof(val))); i != 0 {
 		return errget(err)
@@ -38,6 +52,10 @@ func (soc *Socket) setInt64(opt C.int, value int64) error {
 }
 
 func (soc *Socket) setUInt64(opt C.int, value uint64) error {
+	if soc.soc == nil {
+		return ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	val := C.uint64_t(value)
 	if i, err := C.zmq_setsockopt(soc.soc, opt, unsafe.Pointer(&val), C.size_t(unsafe.Sizeof(val))); i != 0 {
 		return errget(err)
@@ -45,6 +63,35 @@ func (soc *Socket) setUInt64(opt C.int, value uint64) error {
 	return nil
 }
 
+/*
+Set an integer socket option by its number, for options that don't have
+their own function.
+
+For the options, see: http://api.zeromq.org/3-2:zmq-setsockopt
+*/
+func (soc *Socket) SetSockOptInt(opt int, value int) error {
+	return soc.setInt(C.int(opt), value)
+}
+
+/*
+Set a string socket option by its number, for options that don't have
+their own function.
+
+OPT_SUBSCRIBE and OPT_UNSUBSCRIBE are the same as SetSubscribe() and
+SetUnsubscribe(), so the subscriptions are copied by Clone().
+
+For the options, see: http://api.zeromq.org/3-2:zmq-setsockopt
+*/
+func (soc *Socket) SetSockOptString(opt int, value string) error {
+	switch opt {
+	case OPT_SUBSCRIBE:
+		return soc.SetSubscribe(value)
+	case OPT_UNSUBSCRIBE:
+		return soc.SetUnsubscribe(value)
+	}
+	return soc.setString(C.int(opt), value)
+}
+
 // ZMQ_SNDHWM: Set high water mark for outbound messages
 //
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc3
@@ -61,6 +108,8 @@ func (soc *Socket) SetRcvhwm(value int) error {
 
 // ZMQ_AFFINITY: Set I/O thread affinity
 //
+// This is a bitmask, bit N is for I/O thread N in the thread pool.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc5
 func (soc *Socket) SetAffinity(value uint64) error {
 	return soc.setUInt64(C.ZMQ_AFFINITY, value)
@@ -68,20 +117,41 @@ func (soc *Socket) SetAffinity(value uint64) error {
 
 // ZMQ_SUBSCRIBE: Establish message filter
 //
+// An empty filter subscribes to all incoming messages. Calling it multiple
+// times adds multiple filters. The filter may contain any binary data.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc6
 func (soc *Socket) SetSubscribe(filter string) error {
-	return soc.setString(C.ZMQ_SUBSCRIBE, filter)
+	if err := soc.setString(C.ZMQ_SUBSCRIBE, filter); err != nil {
+		return err
+	}
+	soc.subscriptions = append(soc.subscriptions, filter)
+	return nil
 }
 
 // ZMQ_UNSUBSCRIBE: Remove message filter
 //
+// Removes one instance of an existing filter, previously set with SetSubscribe().
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc7
 func (soc *Socket) SetUnsubscribe(filter string) error {
-	return soc.setString(C.ZMQ_UNSUBSCRIBE, filter)
+	if err := soc.setString(C.ZMQ_UNSUBSCRIBE, filter); err != nil {
+		return err
+	}
+	for i, f := range soc.subscriptions {
+		if f == filter {
+			soc.subscriptions = append(soc.subscriptions[:i], soc.subscriptions[i+1:]...)
+			break
+		}
+	}
+	return nil
 }
 
 // ZMQ_IDENTITY: Set socket identity
 //
+// The identity is 1 up to 255 bytes of binary data, it may contain zero bytes.
+// Identities starting with a zero byte are reserved for use by 0MQ.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc8
 func (soc *Socket) SetIdentity(value string) error {
 	return soc.setString(C.ZMQ_IDENTITY, value)
@@ -89,6 +159,8 @@ func (soc *Socket) SetIdentity(value string) error {
 
 // ZMQ_RATE: Set multicast data rate
 //
+// The rate is in kilobits per second. Only used by multicast transports.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc9
 func (soc *Socket) SetRate(value int) error {
 	return soc.setInt(C.ZMQ_RATE, value)
@@ -96,6 +168,8 @@ func (soc *Socket) SetRate(value int) error {
 
 // ZMQ_RECOVERY_IVL: Set multicast recovery interval
 //
+// The interval is used with millisecond precision. Only used by multicast transports.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc10
 func (soc *Socket) SetRecoveryIvl(value time.Duration) error {
 	val := int(value / time.Millisecond)
@@ -104,6 +178,8 @@ func (soc *Socket) SetRecoveryIvl(value time.Duration) error {
 
 // ZMQ_SNDBUF: Set kernel transmit buffer size
 //
+// Use 0 for the OS default.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc11
 func (soc *Socket) SetSndbuf(value int) error {
 	return soc.setInt(C.ZMQ_SNDBUF, value)
@@ -111,6 +187,8 @@ func (soc *Socket) SetSndbuf(value int) error {
 
 // ZMQ_RCVBUF: Set kernel receive buffer size
 //
+// Use 0 for the OS default.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc12
 func (soc *Socket) SetRcvbuf(value int) error {
 	return soc.setInt(C.ZMQ_RCVBUF, value)
@@ -152,6 +230,8 @@ func (soc *Socket) SetReconnectIvlMax(value time.Duration) error {
 
 // ZMQ_BACKLOG: Set maximum length of the queue of outstanding connections
 //
+// Only applies to connection-oriented transports on sockets that bind.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc16
 func (soc *Socket) SetBacklog(value int) error {
 	return soc.setInt(C.ZMQ_BACKLOG, value)
@@ -159,6 +239,8 @@ func (soc *Socket) SetBacklog(value int) error {
 
 // ZMQ_MAXMSGSIZE: Maximum acceptable inbound message size
 //
+// A peer sending a larger message is disconnected. Use -1 for no limit.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc17
 func (soc *Socket) SetMaxmsgsize(value int64) error {
 	return soc.setInt64(C.ZMQ_MAXMSGSIZE, value)
@@ -166,19 +248,24 @@ func (soc *Socket) SetMaxmsgsize(value int64) error {
 
 // ZMQ_MULTICAST_HOPS: Maximum network hops for multicast packets
 //
+// The value must be positive. Default 1, local network only.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc18
 func (soc *Socket) SetMulticastHops(value int) error {
+	if value < 1 {
+		return errors.New("Multicast hops must be positive")
+	}
 	return soc.setInt(C.ZMQ_MULTICAST_HOPS, value)
 }
 
 // ZMQ_RCVTIMEO: Maximum time before a recv operation returns with EAGAIN
 //
-// Use -1 for infinite
+// Use -1 (or any negative value) for infinite, 0 to return immediately
 //
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc19
 func (soc *Socket) SetRcvtimeo(value time.Duration) error {
 	val := int(value / time.Millisecond)
-	if value == -1 {
+	if value < 0 {
 		val = -1
 	}
 	return soc.setInt(C.ZMQ_RCVTIMEO, val)
@@ -186,12 +273,12 @@ func (soc *Socket) SetRcvtimeo(value time.Duration) error {
 
 // ZMQ_SNDTIMEO: Maximum time before a send operation returns with EAGAIN
 //
-// Use -1 for infinite
+// Use -1 (or any negative value) for infinite, 0 to return immediately
 //
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc20
 func (soc *Socket) SetSndtimeo(value time.Duration) error {
 	val := int(value / time.Millisecond)
-	if value == -1 {
+	if value < 0 {
 		val = -1
 	}
 	return soc.setInt(C.ZMQ_SNDTIMEO, val)
@@ -199,6 +286,9 @@ func (soc *Socket) SetSndtimeo(value time.Duration) error {
 
 // ZMQ_IPV4ONLY: Use IPv4-only sockets
 //
+// Default true. Set it to false to bind and connect to IPv6 addresses,
+// such as "tcp://[::1]:5555", in addition to IPv4 addresses.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc21
 func (soc *Socket) SetIpv4only(value bool) error {
 	val := 0
@@ -210,6 +300,10 @@ func (soc *Socket) SetIpv4only(value bool) error {
 
 // ZMQ_DELAY_ATTACH_ON_CONNECT: Accept messages only when connections are made
 //
+// If true, messages are only queued to a connection after it has been
+// established. With multiple endpoints, messages are only distributed
+// round-robin over the peers that are actually connected.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc22
 func (soc *Socket) SetDelayAttachOnConnect(value bool) error {
 	val := int(0)
@@ -219,22 +313,50 @@ func (soc *Socket) SetDelayAttachOnConnect(value bool) error {
 	return soc.setInt(C.ZMQ_DELAY_ATTACH_ON_CONNECT, val)
 }
 
+// ZMQ_IMMEDIATE: Queue messages only to completed connections
+//
+// This is the same option as ZMQ_DELAY_ATTACH_ON_CONNECT, under the name
+// it has since ZeroMQ 3.3. See: func (*Socket) SetDelayAttachOnConnect
+func (soc *Socket) SetImmediate(value bool) error {
+	val := int(0)
+	if value {
+		val = 1
+	}
+	return soc.setInt(C.int(OPT_IMMEDIATE), val)
+}
+
 // ZMQ_ROUTER_MANDATORY: accept only routable messages on ROUTER sockets
 //
+// If true, sending a message to an unknown peer fails with EHOSTUNREACH,
+// instead of the message being dropped silently.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc23
-func (soc *Socket) SetRouterMandatory(value int) error {
-	return soc.setInt(C.ZMQ_ROUTER_MANDATORY, value)
+func (soc *Socket) SetRouterMandatory(value bool) error {
+	val := 0
+	if value {
+		val = 1
+	}
+	return soc.setInt(C.ZMQ_ROUTER_MANDATORY, val)
 }
 
 // ZMQ_XPUB_VERBOSE: provide all subscription messages on XPUB sockets
 //
+// If true, all subscription messages are passed upstream, not only
+// new subscriptions.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc24
-func (soc *Socket) SetXpubVerbose(value int) error {
-	return soc.setInt(C.ZMQ_XPUB_VERBOSE, value)
+func (soc *Socket) SetXpubVerbose(value bool) error {
+	val := 0
+	if value {
+		val = 1
+	}
+	return soc.setInt(C.ZMQ_XPUB_VERBOSE, val)
 }
 
 // ZMQ_TCP_KEEPALIVE: Override SO_KEEPALIVE socket option
 //
+// Use -1 to leave it to the OS default, 0 to switch keepalive off, 1 to switch it on.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc25
 func (soc *Socket) SetTcpKeepalive(value int) error {
 	return soc.setInt(C.ZMQ_TCP_KEEPALIVE, value)
@@ -242,12 +364,18 @@ func (soc *Socket) SetTcpKeepalive(value int) error {
 
 // ZMQ_TCP_KEEPALIVE_IDLE: Override TCP_KEEPCNT(or TCP_KEEPALIVE on some OS)
 //
+// The value is in the units used by the OS, -1 leaves it to the OS default.
+// Not all platforms support this option, in which case it is ignored.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc26
 func (soc *Socket) SetTcpKeepaliveIdle(value int) error {
 	return soc.setInt(C.ZMQ_TCP_KEEPALIVE_IDLE, value)
 }
 
-// ZMQ_TCP_KEEPALIVE_CNT: ZMQ_TCP_KEEPALIVE_CNT: Override TCP_KEEPCNT socket option
+// ZMQ_TCP_KEEPALIVE_CNT: Override TCP_KEEPCNT socket option
+//
+// The value is in the units used by the OS, -1 leaves it to the OS default.
+// Not all platforms support this option, in which case it is ignored.
 //
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc27
 func (soc *Socket) SetTcpKeepaliveCnt(value int) error {
@@ -256,6 +384,9 @@ func (soc *Socket) SetTcpKeepaliveCnt(value int) error {
 
 // ZMQ_TCP_KEEPALIVE_INTVL: Override TCP_KEEPINTVL socket option
 //
+// The value is in the units used by the OS, -1 leaves it to the OS default.
+// Not all platforms support this option, in which case it is ignored.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc28
 func (soc *Socket) SetTcpKeepaliveIntvl(value int) error {
 	return soc.setInt(C.ZMQ_TCP_KEEPALIVE_INTVL, value)
@@ -263,7 +394,22 @@ func (soc *Socket) SetTcpKeepaliveIntvl(value int) error {
 
 // ZMQ_TCP_ACCEPT_FILTER: Assign filters to allow new TCP connections
 //
+// Calling it multiple times adds multiple filters. Incoming connections
+// that don't match any filter are rejected. Use an empty filter to remove
+// all filters.
+//
 // See: http://api.zeromq.org/3-2:zmq-setsockopt#toc29
 func (soc *Socket) SetTcpAcceptFilter(filter string) error {
+	if soc.soc == nil {
+		return ErrSocketClosed
+	}
+	if len(filter) == 0 {
+		defer runtime.KeepAlive(soc)
+		// only a null value with zero length clears the filters
+		if i, err := C.zmq_setsockopt(soc.soc, C.ZMQ_TCP_ACCEPT_FILTER, nil, 0); i != 0 {
+			return errget(err)
+		}
+		return nil
+	}
 	return soc.setString(C.ZMQ_TCP_ACCEPT_FILTER, filter)
 }
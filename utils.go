@@ -1,9 +1,25 @@
 package zmq3
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// How often RecvContext and SendContext check for cancellation.
+const contextPollInterval = 100 * time.Millisecond
+
+var inprocCount uint64
+
+// A new inproc endpoint, not used before by this package.
+func inprocEndpoint(name string) string {
+	return fmt.Sprintf("inproc://zmq3.%s.%d", name, atomic.AddUint64(&inprocCount, 1))
+}
+
 /*
 Send multi-part message on socket.
 
@@ -12,9 +28,23 @@ Any `[]string' or `[][]byte' is split into separate `string's or `[]byte's
 Any other part that isn't a `string' or `[]byte' is converted
 to `string' with `fmt.Sprintf("%v", part)'.
 
+If there are no parts, or only empty `[]string's or `[][]byte's, a
+single empty message part is sent.
+
 Returns total bytes sent.
 */
 func (soc *Socket) SendMessage(parts ...interface{}) (total int, err error) {
+	return soc.sendMessage(0, parts...)
+}
+
+/*
+Like SendMessage(), but adding the DONTWAIT flag to each part.
+*/
+func (soc *Socket) SendMessageDontwait(parts ...interface{}) (total int, err error) {
+	return soc.sendMessage(DONTWAIT, parts...)
+}
+
+func (soc *Socket) sendMessage(dontwait Flag, parts ...interface{}) (total int, err error) {
 	// TODO: make this faster
 
 	// flatten first, just in case the last part may be an empty []string or [][]byte
@@ -36,12 +66,13 @@ func (soc *Socket) SendMessage(parts ...interface{}) (total int, err error) {
 
 	n := len(pp)
 	if n == 0 {
-		return
+		pp = append(pp, "")
+		n = 1
 	}
-	opt := SNDMORE
+	opt := SNDMORE | dontwait
 	for i, p := range pp {
 		if i == n-1 {
-			opt = 0
+			opt = dontwait
 		}
 		switch t := p.(type) {
 		case string:
@@ -74,24 +105,16 @@ func (soc *Socket) SendMessage(parts ...interface{}) (total int, err error) {
 Receive parts as message from socket.
 
 Returns last non-nil error code.
+
+If flags contains DONTWAIT and no message is available, the error is
+EAGAIN, and nothing has been received. If an error occurs after the first
+part was received, no parts are returned, never a partial message.
 */
 func (soc *Socket) RecvMessage(flags Flag) (msg []string, err error) {
-	msg = make([]string, 0)
-	for {
-		s, e := soc.Recv(flags)
-		if e == nil {
-			msg = append(msg, s)
-		} else {
-			return msg[0:0], e
-		}
-		more, e := soc.GetRcvmore()
-		if e == nil {
-			if !more {
-				break
-			}
-		} else {
-			return msg[0:0], e
-		}
+	bb, err := soc.RecvMessageBytes(flags)
+	msg = make([]string, len(bb))
+	for i, b := range bb {
+		msg[i] = string(b)
 	}
 	return
 }
@@ -100,6 +123,10 @@ func (soc *Socket) RecvMessage(flags Flag) (msg []string, err error) {
 Receive parts as message from socket.
 
 Returns last non-nil error code.
+
+If flags contains DONTWAIT and no message is available, the error is
+EAGAIN, and nothing has been received. If an error occurs after the first
+part was received, no parts are returned, never a partial message.
 */
 func (soc *Socket) RecvMessageBytes(flags Flag) (msg [][]byte, err error) {
 	msg = make([][]byte, 0)
@@ -121,3 +148,497 @@ func (soc *Socket) RecvMessageBytes(flags Flag) (msg [][]byte, err error) {
 	}
 	return
 }
+
+/*
+Receive parts as message from socket, with limits on the number of parts,
+and on the total size of the parts.
+
+If a limit is exceeded, the rest of the message is received and
+discarded, and an error is returned. Use a limit of 0 or less for no
+limit. This protects against messages from untrusted peers that consist
+of very many parts. For a limit on the size of a single part, use
+SetMaxmsgsize().
+*/
+func (soc *Socket) RecvMessageCapped(maxParts int, maxTotalBytes int) ([][]byte, error) {
+	msg := make([][]byte, 0)
+	total := 0
+	for {
+		b, err := soc.RecvBytes(0)
+		if err != nil {
+			return [][]byte{}, err
+		}
+		msg = append(msg, b)
+		total += len(b)
+		more, err := soc.GetRcvmore()
+		if err != nil {
+			return [][]byte{}, err
+		}
+		var limit error
+		if maxParts > 0 && len(msg) > maxParts {
+			limit = fmt.Errorf("Message has more than %d parts", maxParts)
+		} else if maxTotalBytes > 0 && total > maxTotalBytes {
+			limit = fmt.Errorf("Message is larger than %d bytes", maxTotalBytes)
+		}
+		if limit != nil {
+			for more && err == nil {
+				if _, err = soc.RecvBytes(0); err == nil {
+					more, err = soc.GetRcvmore()
+				}
+			}
+			return [][]byte{}, limit
+		}
+		if !more {
+			return msg, nil
+		}
+	}
+}
+
+/*
+Bind the socket to each of the endpoints.
+
+Stops at the first endpoint that fails, returning an error that names
+that endpoint. The endpoints bound before that are left as they are.
+*/
+func (soc *Socket) BindAll(endpoints ...string) error {
+	for _, endpoint := range endpoints {
+		if err := soc.Bind(endpoint); err != nil {
+			return fmt.Errorf("Bind %s: %w", endpoint, err)
+		}
+	}
+	return nil
+}
+
+/*
+Connect the socket to each of the endpoints.
+
+Stops at the first endpoint that fails, returning an error that names
+that endpoint. The endpoints connected before that are left as they are.
+*/
+func (soc *Socket) ConnectAll(endpoints ...string) error {
+	for _, endpoint := range endpoints {
+		if err := soc.Connect(endpoint); err != nil {
+			return fmt.Errorf("Connect %s: %w", endpoint, err)
+		}
+	}
+	return nil
+}
+
+// Time to wait for the socket, until the next check of the context.
+func contextTimeout(ctx context.Context) time.Duration {
+	timeout := contextPollInterval
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := deadline.Sub(time.Now()); d < timeout {
+			timeout = d
+		}
+	}
+	if timeout < time.Millisecond {
+		timeout = time.Millisecond
+	}
+	return timeout
+}
+
+/*
+Receive a message part from a socket, until the context is cancelled or
+its deadline passes.
+
+Returns ctx.Err() if the context is done before a message part is received.
+The context is checked at least every 100 milliseconds.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-msg-recv#toc2
+*/
+func (soc *Socket) RecvContext(ctx context.Context, flags Flag) ([]byte, error) {
+	p := NewPoller()
+	p.Add(soc, POLLIN)
+	for {
+		if err := ctx.Err(); err != nil {
+			return []byte{}, err
+		}
+		b, err := soc.RecvBytes(flags | DONTWAIT)
+		if err != ErrEAGAIN || flags&DONTWAIT != 0 {
+			return b, err
+		}
+		if _, err := p.Poll(contextTimeout(ctx)); err != nil {
+			return []byte{}, err
+		}
+	}
+}
+
+/*
+Send a message part on a socket, until the context is cancelled or its
+deadline passes.
+
+Returns ctx.Err() if the context is done before the message part could be
+queued, for instance because the high water mark was reached. In that
+case nothing was sent, and the socket can still be used.
+The context is checked at least every 100 milliseconds.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-send#toc2
+*/
+func (soc *Socket) SendContext(ctx context.Context, data []byte, flags Flag) (int, error) {
+	p := NewPoller()
+	p.Add(soc, POLLOUT)
+	for {
+		if err := ctx.Err(); err != nil {
+			return -1, err
+		}
+		n, err := soc.SendBytes(data, flags|DONTWAIT)
+		if err != ErrEAGAIN || flags&DONTWAIT != 0 {
+			return n, err
+		}
+		if _, err := p.Poll(contextTimeout(ctx)); err != nil {
+			return -1, err
+		}
+	}
+}
+
+/*
+Receive a message part from a socket, waiting at most for the duration d.
+
+If nothing was received in time, ok is false and err is nil. The option
+ZMQ_RCVTIMEO of the socket is set for the duration of the call, and
+restored afterwards.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-msg-recv#toc2
+*/
+func (soc *Socket) RecvTimeout(d time.Duration, flags Flag) (data []byte, ok bool, err error) {
+	old, err := soc.GetRcvtimeo()
+	if err != nil {
+		return []byte{}, false, err
+	}
+	if d < 0 {
+		d = 0
+	}
+	if err = soc.SetRcvtimeo(d); err != nil {
+		return []byte{}, false, err
+	}
+	data, err = soc.RecvBytes(flags)
+	if e := soc.SetRcvtimeo(old); e != nil && err == nil {
+		return data, true, e
+	}
+	if err == ErrEAGAIN {
+		return []byte{}, false, nil
+	}
+	if err != nil {
+		return []byte{}, false, err
+	}
+	return data, true, nil
+}
+
+/*
+Bind the socket to a TCP address and port.
+
+Use port 0 to bind to a free port chosen by the system. Returns the port
+the socket is bound to.
+
+An IPv6 address, such as "::1", is put between brackets. Binding to it
+needs IPv6 to be enabled on the socket, with SetIpv4only(false).
+
+Example:
+
+    port, err := soc.BindTCP("127.0.0.1", 0)
+*/
+func (soc *Socket) BindTCP(addr string, port int) (int, error) {
+	p := strconv.Itoa(port)
+	if port == 0 {
+		p = "*"
+	}
+	if err := soc.Bind("tcp://" + net.JoinHostPort(addr, p)); err != nil {
+		return 0, err
+	}
+	if port != 0 {
+		return port, nil
+	}
+	endpoint, err := soc.GetLastEndpoint()
+	if err != nil {
+		return 0, err
+	}
+	_, p, err = net.SplitHostPort(strings.TrimPrefix(endpoint, "tcp://"))
+	if err != nil {
+		return 0, fmt.Errorf("No port in endpoint %q", endpoint)
+	}
+	return strconv.Atoi(p)
+}
+
+// Parts of an endpoint, as returned by (*Socket)BindInfo()
+type EndpointInfo struct {
+	Transport string // such as "tcp" or "ipc"
+	Address   string // host or interface for tcp, pgm and epgm, full address for other transports
+	Port      int    // port for tcp, pgm and epgm, zero for other transports
+}
+
+/*
+Bind the socket to the endpoint, and return the parts of the endpoint
+the socket is actually bound to.
+
+This is useful with wildcards, such as "tcp://*:*".
+
+Example:
+
+    info, err := soc.BindInfo("tcp://127.0.0.1:*")
+    //  info.Port is the port chosen by the system
+*/
+func (soc *Socket) BindInfo(endpoint string) (EndpointInfo, error) {
+	if err := soc.Bind(endpoint); err != nil {
+		return EndpointInfo{}, err
+	}
+	last, err := soc.GetLastEndpoint()
+	if err != nil {
+		return EndpointInfo{}, err
+	}
+	transport, address, err := ParseEndpoint(last)
+	if err != nil {
+		return EndpointInfo{}, err
+	}
+	info := EndpointInfo{Transport: transport, Address: address}
+	switch transport {
+	case "tcp", "pgm", "epgm":
+		j := strings.LastIndex(address, ":")
+		info.Port, err = strconv.Atoi(address[j+1:])
+		if err != nil {
+			return EndpointInfo{}, fmt.Errorf("Invalid port in endpoint %q", last)
+		}
+		info.Address = strings.TrimSuffix(strings.TrimPrefix(address[:j], "["), "]")
+	}
+	return info, nil
+}
+
+/*
+Split an endpoint into transport and address, and check that it is valid.
+
+The transport must be one of "tcp", "ipc", "inproc", "pgm" or "epgm".
+For tcp, pgm and epgm, the address must end with a colon and a port
+number, or a "*" for tcp. Other transports need a non-empty address.
+
+This only checks the form of the endpoint, for instance when reading a
+configuration. Bind() or Connect() can still fail, if the interface or
+host doesn't exist.
+
+Example:
+
+    transport, address, err := zmq.ParseEndpoint("tcp://localhost:5555")
+    //  "tcp", "localhost:5555", nil
+*/
+func ParseEndpoint(s string) (transport, address string, err error) {
+	i := strings.Index(s, "://")
+	if i < 0 {
+		return "", "", fmt.Errorf("No transport in endpoint %q", s)
+	}
+	transport, address = s[:i], s[i+3:]
+	if address == "" {
+		return "", "", fmt.Errorf("No address in endpoint %q", s)
+	}
+	switch transport {
+	case "ipc", "inproc":
+	case "tcp", "pgm", "epgm":
+		j := strings.LastIndex(address, ":")
+		if j < 1 || j == len(address)-1 {
+			return "", "", fmt.Errorf("No host and port in endpoint %q", s)
+		}
+		port := address[j+1:]
+		if port == "*" && transport == "tcp" {
+			break
+		}
+		if n, e := strconv.Atoi(port); e != nil || n < 0 || n > 65535 {
+			return "", "", fmt.Errorf("Invalid port in endpoint %q", s)
+		}
+	default:
+		return "", "", fmt.Errorf("Unknown transport in endpoint %q", s)
+	}
+	return transport, address, nil
+}
+
+/*
+Send a message part, with more parts to follow.
+
+This is the same as SendBytes(data, SNDMORE).
+*/
+func (soc *Socket) SendMore(data []byte) (int, error) {
+	return soc.SendBytes(data, SNDMORE)
+}
+
+/*
+Send the last message part of a message.
+
+This is the same as SendBytes(data, 0).
+*/
+func (soc *Socket) SendLast(data []byte) (int, error) {
+	return soc.SendBytes(data, 0)
+}
+
+/*
+Receive a message part, and report whether more parts follow.
+
+This is the same as RecvBytes(0) followed by GetRcvmore().
+*/
+func (soc *Socket) RecvMore() (data []byte, hasMore bool, err error) {
+	data, err = soc.RecvBytes(0)
+	if err != nil {
+		return
+	}
+	hasMore, err = soc.GetRcvmore()
+	return
+}
+
+/*
+Discard all messages that are waiting on the socket.
+
+Messages are received with DONTWAIT until none are left. Multi-part
+messages are discarded whole. Returns the number of messages discarded.
+*/
+func (soc *Socket) Drain() (int, error) {
+	n := 0
+	for {
+		_, err := soc.RecvMessageBytes(DONTWAIT)
+		if err == ErrEAGAIN {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+/*
+Connect the socket to the endpoint, and wait until the connection is
+established, or the timeout has passed.
+
+This uses the monitor of the socket, replacing any monitor set with
+Monitor(). When this function returns, the socket is no longer monitored.
+If the timeout passes, the socket stays connected, and the connection may
+still be established later.
+
+Any connection made by the socket while waiting counts, also when an
+existing connection to another endpoint is re-established.
+
+Only connections over tcp:// and ipc:// are observed. 0MQ version 3
+reports no events for inproc://, so for an inproc endpoint this function
+just connects, and returns without waiting.
+*/
+func (soc *Socket) WaitForConnect(endpoint string, timeout time.Duration) error {
+	if strings.HasPrefix(endpoint, "inproc://") {
+		return soc.Connect(endpoint)
+	}
+
+	addr := inprocEndpoint("monitor")
+	if err := soc.Monitor(addr, EVENT_CONNECTED); err != nil {
+		return err
+	}
+	defer soc.Monitor("", 0)
+
+	mon, err := soc.ctx.NewMonitorSocket(addr)
+	if err != nil {
+		return err
+	}
+	defer mon.Close()
+
+	if err := soc.Connect(endpoint); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	poller := NewPoller()
+	poller.Add(mon, POLLIN)
+	for {
+		d := deadline.Sub(time.Now())
+		if d <= 0 {
+			return fmt.Errorf("Not connected to %s within %v", endpoint, timeout)
+		}
+		polled, err := poller.Poll(d)
+		if err != nil {
+			return err
+		}
+		if len(polled) == 0 {
+			continue
+		}
+		// Only EVENT_CONNECTED is monitored, so any event is the connection.
+		// Its address is not compared with the endpoint: 0MQ reports the
+		// resolved address, such as 127.0.0.1 for localhost.
+		if _, err := mon.RecvBytes(0); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+/*
+Connect the socket to the endpoint, and wait until the connection is
+established, or the timeout has passed.
+
+While waiting, 0MQ retries to connect, with the interval set by
+SetReconnectIvl(). This is like WaitForConnect(), except that if the
+connection is not established in time, the socket is disconnected from
+the endpoint again, so no connection is made later.
+
+For an inproc:// endpoint there is no waiting, and the connection is kept.
+*/
+func (soc *Socket) ConnectWait(endpoint string, timeout time.Duration) error {
+	err := soc.WaitForConnect(endpoint, timeout)
+	if err != nil {
+		soc.Disconnect(endpoint)
+	}
+	return err
+}
+
+/*
+Create two connected PAIR sockets in the default context.
+
+See: func (*Context) NewInprocPair
+*/
+func NewInprocPair() (server, client *Socket, err error) {
+	return defaultCtx.NewInprocPair()
+}
+
+/*
+Create two connected PAIR sockets in the given context.
+
+The server is bound and the client is connected to a new inproc endpoint,
+with a unique name. This is useful for communication between goroutines,
+giving each goroutine one of the sockets.
+*/
+func (ctx *Context) NewInprocPair() (server, client *Socket, err error) {
+	endpoint := inprocEndpoint("pair")
+	server, err = ctx.NewSocket(PAIR)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = server.Bind(endpoint); err != nil {
+		server.Close()
+		return nil, nil, err
+	}
+	client, err = ctx.NewSocket(PAIR)
+	if err != nil {
+		server.Close()
+		return nil, nil, err
+	}
+	if err = client.Connect(endpoint); err != nil {
+		client.Close()
+		server.Close()
+		return nil, nil, err
+	}
+	return server, client, nil
+}
+
+/*
+Send a message part without blocking, and report whether it was dropped
+because the high water mark was reached.
+
+The message part is sent with flag DONTWAIT added. If it can't be queued
+(EAGAIN), dropped is true, and err is nil. For socket types that block
+when the high water mark is reached, such as PUSH, DEALER and REQ, this
+gives a count of dropped messages.
+
+For PUB and XPUB sockets, and for ROUTER sockets without the option
+ZMQ_ROUTER_MANDATORY, 0MQ drops messages silently, and doesn't report
+this in any way, not even with the socket monitor. For those, dropped is
+always false.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-send#toc2
+*/
+func (soc *Socket) SendCounted(data []byte, flags Flag) (sent int, dropped bool, err error) {
+	sent, err = soc.SendBytes(data, flags|DONTWAIT)
+	if err == ErrEAGAIN {
+		return 0, true, nil
+	}
+	return
+}
@@ -0,0 +1,159 @@
+package zmq3
+
+import (
+	"errors"
+)
+
+var errPoolStopped = errors.New("Worker pool is stopped")
+
+/*
+A load balancing broker, that passes requests from clients to the worker
+that has been waiting the longest.
+
+This is the load balancing pattern, see: http://zguide.zeromq.org/page:all#toc72
+
+Clients are REQ sockets, connecting to the frontend endpoint. Workers are
+REQ sockets, connecting to the backend endpoint. A worker starts by
+sending a single message part "READY". Each request is passed to the
+worker with the envelope of the client. The worker sends its reply with
+the same envelope, and that reply is its signal that it is ready again.
+
+Example of a worker:
+
+    worker, _ := zmq.NewSocket(zmq.REQ)
+    worker.Connect("ipc://backend.ipc")
+    worker.Send("READY", 0)
+    for {
+        msg, _ := worker.RecvMessage(0)
+        //  msg[0] and msg[1] are the envelope, msg[2:] is the request
+        worker.SendMessage(msg[0], "", "OK")
+    }
+*/
+type WorkerPool struct {
+	frontend *Socket
+	backend  *Socket
+	stop     chan bool
+	done     chan bool
+}
+
+/*
+Create a load balancing broker, with a ROUTER socket bound to frontend
+for clients, and a ROUTER socket bound to backend for workers.
+
+The broker doesn't run until Run() is called.
+*/
+func NewWorkerPool(ctx *Context, frontend, backend string) (*WorkerPool, error) {
+	p := &WorkerPool{
+		stop: make(chan bool),
+		done: make(chan bool),
+	}
+	var err error
+	if p.frontend, err = ctx.NewSocket(ROUTER); err != nil {
+		return nil, err
+	}
+	if p.backend, err = ctx.NewSocket(ROUTER); err != nil {
+		p.frontend.Close()
+		return nil, err
+	}
+	if err = p.frontend.Bind(frontend); err == nil {
+		err = p.backend.Bind(backend)
+	}
+	if err != nil {
+		p.frontend.Close()
+		p.backend.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+/*
+Run the broker, until Stop() is called, or until an error occurs.
+
+When Run returns, the sockets of the broker are closed. It returns nil
+after Stop(), or else the error, for instance ETERM when the context was
+terminated.
+*/
+func (p *WorkerPool) Run() error {
+	defer close(p.done)
+	defer p.backend.Close()
+	defer p.frontend.Close()
+
+	//  Queue of available workers
+	workers := make([][]byte, 0)
+
+	backendOnly := NewPoller()
+	backendOnly.Add(p.backend, POLLIN)
+	both := NewPoller()
+	both.Add(p.backend, POLLIN)
+	both.Add(p.frontend, POLLIN)
+
+	for {
+		select {
+		case <-p.stop:
+			return nil
+		default:
+		}
+
+		//  Poll frontend only if we have available workers
+		poller := backendOnly
+		if len(workers) > 0 {
+			poller = both
+		}
+		sockets, err := poller.Poll(contextPollInterval)
+		if err != nil {
+			return err
+		}
+		for _, socket := range sockets {
+			switch socket.Socket {
+			case p.backend:
+				//  worker identity, empty, then READY or a reply with client envelope
+				msg, err := p.backend.RecvMessageBytes(0)
+				if err != nil {
+					return err
+				}
+				if len(msg) < 3 {
+					continue
+				}
+				workers = append(workers, msg[0])
+				reply := msg[2:]
+				if len(reply) == 1 && string(reply[0]) == "READY" {
+					continue
+				}
+				if _, err := p.frontend.SendMessage(reply); err != nil {
+					return err
+				}
+			case p.frontend:
+				//  client envelope and request, passed to the first worker
+				msg, err := p.frontend.RecvMessageBytes(0)
+				if err != nil {
+					return err
+				}
+				worker := workers[0]
+				workers = workers[1:]
+				if _, err := p.backend.SendMessage(worker, "", msg); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+/*
+Stop the broker, and wait for Run() to return.
+
+Returns an error if the broker was already stopped. Don't call Stop()
+unless Run() was started.
+*/
+func (p *WorkerPool) Stop() error {
+	select {
+	case <-p.done:
+		return errPoolStopped
+	default:
+	}
+	select {
+	case p.stop <- true:
+	case <-p.done:
+	}
+	<-p.done
+	return nil
+}
@@ -12,6 +12,10 @@ package zmq3
 #include <zmq.h>
 #include <stdlib.h>
 #include <string.h>
+#ifndef ZMQ_IMMEDIATE
+// renamed in ZeroMQ 3.3, same value
+#define ZMQ_IMMEDIATE ZMQ_DELAY_ATTACH_ON_CONNECT
+#endif
 char *get_event(zmq_msg_t *msg, int *ev, int *val) {
     zmq_event_t event;
     char *s;
@@ -35,28 +39,88 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"unsafe"
+	"weak"
 )
 
 var (
-	ctx unsafe.Pointer
+	defaultCtx *Context
+
+	errMsgClosed = errors.New("Message is closed")
+)
+
+var (
+	// Returned when using a context after it was terminated.
+	ErrContextClosed = errors.New("Context is closed")
+	// Returned when using a socket after it was closed.
+	ErrSocketClosed = errors.New("Socket is closed")
 )
 
 func init() {
 	var err error
-	ctx, err = C.zmq_ctx_new()
-	if ctx == nil {
+	defaultCtx = &Context{}
+	defaultCtx.ctx, err = C.zmq_ctx_new()
+	if defaultCtx.ctx == nil {
 		panic("Init of ZeroMQ context failed: " + errget(err).Error())
 	}
+	defaultCtx.opened = true
+	defaultCtx.retryEINTR = true
 }
 
 //. Util
 
+/*
+An error number, as returned by 0MQ.
+
+Errors returned by this package that represent one of the Err... values
+below can be compared to these values directly, or with errors.Is().
+*/
+type Errno uintptr
+
+const (
+	// Errors that can be compared with ==
+	ErrEAGAIN         = Errno(C.EAGAIN)
+	ErrEINTR          = Errno(C.EINTR)
+	ErrETERM          = Errno(C.ETERM)
+	ErrEFSM           = Errno(C.EFSM)
+	ErrENOCOMPATPROTO = Errno(C.ENOCOMPATPROTO)
+	ErrEMTHREAD       = Errno(C.EMTHREAD)
+)
+
+// Error message, as given by 0MQ.
+func (e Errno) Error() string {
+	return C.GoString(C.zmq_strerror(C.int(e)))
+}
+
+// An Errno also matches the syscall.Errno with the same number.
+func (e Errno) Is(target error) bool {
+	t, ok := target.(syscall.Errno)
+	return ok && Errno(t) == e
+}
+
+// An Errno can be converted to the syscall.Errno with the same number.
+func (e Errno) As(target any) bool {
+	t, ok := target.(*syscall.Errno)
+	if ok {
+		*t = syscall.Errno(e)
+	}
+	return ok
+}
+
 func errget(err error) error {
 	errno, ok := err.(syscall.Errno)
-	if ok && errno >= C.ZMQ_HAUSNUMERO {
-		return errors.New(C.GoString(C.zmq_strerror(C.int(errno))))
+	if !ok {
+		return err
+	}
+	switch e := Errno(errno); e {
+	case ErrEAGAIN, ErrEINTR:
+		return e
+	}
+	if errno >= C.ZMQ_HAUSNUMERO {
+		return Errno(errno)
 	}
 	return err
 }
@@ -69,14 +133,288 @@ func Version() (major, minor, patch int) {
 }
 
 // Get 0MQ error message string.
+//
+// This works for any error number, both 0MQ specific and system errors,
+// as given by zmq_strerror().
+//
+// There is no function to get the current error number: errno is thread
+// local, and a goroutine may switch threads between the call to 0MQ and
+// the call to retrieve errno. Errors returned by functions in this package
+// are captured directly after the call to 0MQ. They are an Errno or a
+// syscall.Errno, and an Errno can also be converted to a syscall.Errno
+// with errors.As().
 func Error(e int) string {
 	return C.GoString(C.zmq_strerror(C.int(e)))
 }
 
 //. Context
 
-func getOption(o C.int) (int, error) {
-	nc, err := C.zmq_ctx_get(ctx, o)
+/*
+A context that is not the default context.
+
+Sockets created with NewSocket() use the default context. Use
+NewContext() and (*Context)NewSocket() when you need a separate context.
+*/
+type Context struct {
+	ctx     unsafe.Pointer
+	opened  bool
+	err     error
+	sockets map[uint64]weak.Pointer[Socket]
+	lastID  uint64
+	mu      sync.Mutex
+
+	retryEINTR bool
+	ipv6       bool
+	defaults   []sockOptDefault
+}
+
+type sockOptDefault struct {
+	opt   int
+	value int
+}
+
+/*
+Create a new context.
+
+If not terminated explicitly, the context is terminated on garbage
+collection, which can only happen after all its sockets were closed or
+garbage collected.
+*/
+func NewContext() (ctx *Context, err error) {
+	ctx = &Context{}
+	c, e := C.zmq_ctx_new()
+	if c == nil {
+		err = errget(e)
+		ctx.err = err
+	} else {
+		ctx.ctx = c
+		ctx.opened = true
+		ctx.retryEINTR = true
+		runtime.SetFinalizer(ctx, (*Context).Term)
+	}
+	return
+}
+
+/*
+Terminates the context.
+
+All sockets created with this context that are still open are closed
+first. Messages not yet sent by those sockets are handled according to
+their linger period, so with an infinite linger termination may still
+wait for pending messages to be delivered. Use (*Socket)SetLinger() to
+avoid this.
+
+It is safe to call Term more than once, also from different goroutines.
+Later calls return nil, or the error from the first call.
+
+For linger, see: http://api.zeromq.org/3-2:zmq-setsockopt#toc13
+*/
+func (ctx *Context) Term() error {
+	ctx.mu.Lock()
+	if !ctx.opened {
+		ctx.mu.Unlock()
+		return ctx.err
+	}
+	ctx.opened = false
+	sockets := ctx.sockets
+	ctx.sockets = nil
+	ctx.mu.Unlock()
+
+	for _, w := range sockets {
+		if soc := w.Value(); soc != nil {
+			soc.Close()
+		}
+	}
+
+	if i, err := C.zmq_ctx_destroy(ctx.ctx); int(i) != 0 {
+		err = errget(err)
+		ctx.mu.Lock()
+		ctx.err = err
+		ctx.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+/*
+Terminates the default context.
+
+See: func (*Context) Term
+*/
+func Term() error {
+	return defaultCtx.Term()
+}
+
+// The context only keeps weak pointers to its sockets. Sockets keep a
+// pointer to their context, so a context can't be garbage collected, and
+// terminated by its finalizer, before all its sockets are closed.
+//
+// Sockets are removed by id: when a socket is closed by its finalizer,
+// its weak pointer is already nil.
+func (ctx *Context) addSocket(soc *Socket) {
+	ctx.mu.Lock()
+	if ctx.sockets == nil {
+		ctx.sockets = make(map[uint64]weak.Pointer[Socket])
+	}
+	ctx.lastID++
+	soc.id = ctx.lastID
+	ctx.sockets[soc.id] = weak.Make(soc)
+	ctx.mu.Unlock()
+}
+
+func (ctx *Context) removeSocket(soc *Socket) {
+	ctx.mu.Lock()
+	delete(ctx.sockets, soc.id)
+	ctx.mu.Unlock()
+}
+
+/*
+Sets the behavior of blocking calls on sockets in the default context,
+when they are interrupted by a signal (EINTR).
+
+See: func (*Context) SetRetryAfterEINTR
+*/
+func SetRetryAfterEINTR(retry bool) {
+	defaultCtx.SetRetryAfterEINTR(retry)
+}
+
+/*
+Sets the behavior of blocking calls on sockets in this context, when they
+are interrupted by a signal (EINTR).
+
+If true (the default), Send and Recv calls without the DONTWAIT flag are
+restarted. If false, they return with ErrEINTR, so you can handle signals
+caught by your program.
+*/
+func (ctx *Context) SetRetryAfterEINTR(retry bool) {
+	ctx.mu.Lock()
+	ctx.retryEINTR = retry
+	ctx.mu.Unlock()
+}
+
+/*
+Sets the IPv6 default for new sockets in the default context.
+
+See: func (*Context) SetIpv6
+*/
+func SetIpv6(value bool) {
+	defaultCtx.SetIpv6(value)
+}
+
+/*
+Sets the IPv6 default for new sockets in this context.
+
+If true, new sockets are created with the option ZMQ_IPV4ONLY set to
+false, so they can use IPv6 addresses. You can still change this per
+socket with (*Socket)SetIpv4only().
+
+Default value   false
+*/
+func (ctx *Context) SetIpv6(value bool) {
+	ctx.mu.Lock()
+	ctx.ipv6 = value
+	ctx.mu.Unlock()
+}
+
+// Returns the IPv6 default for new sockets in the default context.
+func GetIpv6() bool {
+	return defaultCtx.GetIpv6()
+}
+
+// Returns the IPv6 default for new sockets in this context.
+func (ctx *Context) GetIpv6() bool {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.ipv6
+}
+
+/*
+Sets a default for an integer socket option, for new sockets in the default context.
+
+See: func (*Context) SetDefaultSockOpt
+*/
+func SetDefaultSockOpt(opt int, value int) error {
+	return defaultCtx.SetDefaultSockOpt(opt, value)
+}
+
+/*
+Sets a default for an integer socket option, for new sockets in this context.
+
+Opt is one of the OPT_... constants, such as OPT_LINGER or OPT_SNDHWM.
+The value is as used by (*Socket)SetSockOptInt(), so durations are in
+milliseconds.
+
+Only integer options that can be set on every type of socket are allowed,
+for other options an error is returned. These are OPT_SNDHWM, OPT_RCVHWM,
+OPT_RATE, OPT_RECOVERY_IVL, OPT_SNDBUF, OPT_RCVBUF, OPT_LINGER,
+OPT_RECONNECT_IVL, OPT_RECONNECT_IVL_MAX, OPT_BACKLOG, OPT_MULTICAST_HOPS,
+OPT_RCVTIMEO, OPT_SNDTIMEO, OPT_IPV4ONLY, OPT_TCP_KEEPALIVE,
+OPT_TCP_KEEPALIVE_CNT, OPT_TCP_KEEPALIVE_IDLE, OPT_TCP_KEEPALIVE_INTVL and
+OPT_IMMEDIATE. The value is not checked: an invalid value makes NewSocket()
+fail.
+
+Defaults are applied to each new socket in the order they were set. A new
+default for the same option replaces the old one. Sockets that already
+exist are not changed, and the options can still be changed per socket.
+
+Example:
+
+    ctx.SetDefaultSockOpt(zmq.OPT_LINGER, 0)
+*/
+func (ctx *Context) SetDefaultSockOpt(opt int, value int) error {
+	switch opt {
+	case OPT_SNDHWM, OPT_RCVHWM, OPT_RATE, OPT_RECOVERY_IVL, OPT_SNDBUF,
+		OPT_RCVBUF, OPT_LINGER, OPT_RECONNECT_IVL, OPT_RECONNECT_IVL_MAX,
+		OPT_BACKLOG, OPT_MULTICAST_HOPS, OPT_RCVTIMEO, OPT_SNDTIMEO,
+		OPT_IPV4ONLY, OPT_TCP_KEEPALIVE, OPT_TCP_KEEPALIVE_CNT,
+		OPT_TCP_KEEPALIVE_IDLE, OPT_TCP_KEEPALIVE_INTVL, OPT_IMMEDIATE:
+	default:
+		return fmt.Errorf("Socket option %d can't be a default", opt)
+	}
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	for i, d := range ctx.defaults {
+		if d.opt == opt {
+			ctx.defaults = append(ctx.defaults[:i], ctx.defaults[i+1:]...)
+			break
+		}
+	}
+	ctx.defaults = append(ctx.defaults, sockOptDefault{opt: opt, value: value})
+	return nil
+}
+
+const (
+	// Options for (*Context)SetOption() and (*Context)GetOption()
+	// See: http://api.zeromq.org/3-2:zmq-ctx-set
+	CTX_IO_THREADS  = int(C.ZMQ_IO_THREADS)
+	CTX_MAX_SOCKETS = int(C.ZMQ_MAX_SOCKETS)
+)
+
+/*
+Get a context option by its number, for options that don't have their
+own function.
+
+See: http://api.zeromq.org/3-2:zmq-ctx-get
+*/
+func (ctx *Context) GetOption(opt int) (int, error) {
+	return ctx.getOption(C.int(opt))
+}
+
+/*
+Set a context option by its number, for options that don't have their
+own function.
+
+See: http://api.zeromq.org/3-2:zmq-ctx-set
+*/
+func (ctx *Context) SetOption(opt int, value int) error {
+	return ctx.setOption(C.int(opt), value)
+}
+
+func (ctx *Context) getOption(o C.int) (int, error) {
+	if !ctx.opened {
+		return 0, ErrContextClosed
+	}
+	nc, err := C.zmq_ctx_get(ctx.ctx, o)
 	n := int(nc)
 	if n < 0 {
 		return n, errget(err)
@@ -84,24 +422,46 @@ func getOption(o C.int) (int, error) {
 	return n, nil
 }
 
-// Returns the size of the 0MQ thread pool.
+// Returns the size of the 0MQ thread pool in the default context.
 func GetIoThreads() (int, error) {
-	return getOption(C.ZMQ_IO_THREADS)
+	return defaultCtx.GetIoThreads()
 }
 
-// Returns the maximum number of sockets allowed.
+// Returns the size of the 0MQ thread pool.
+func (ctx *Context) GetIoThreads() (int, error) {
+	return ctx.getOption(C.ZMQ_IO_THREADS)
+}
+
+// Returns the maximum number of sockets allowed in the default context.
 func GetMaxSockets() (int, error) {
-	return getOption(C.ZMQ_MAX_SOCKETS)
+	return defaultCtx.GetMaxSockets()
+}
+
+// Returns the maximum number of sockets allowed.
+func (ctx *Context) GetMaxSockets() (int, error) {
+	return ctx.getOption(C.ZMQ_MAX_SOCKETS)
 }
 
-func setOption(o C.int, n int) error {
-	i, err := C.zmq_ctx_set(ctx, o, C.int(n))
+func (ctx *Context) setOption(o C.int, n int) error {
+	if !ctx.opened {
+		return ErrContextClosed
+	}
+	i, err := C.zmq_ctx_set(ctx.ctx, o, C.int(n))
 	if int(i) != 0 {
 		return errget(err)
 	}
 	return nil
 }
 
+/*
+Specifies the size of the 0MQ thread pool in the default context.
+
+See: func (*Context) SetIoThreads
+*/
+func SetIoThreads(n int) error {
+	return defaultCtx.SetIoThreads(n)
+}
+
 /*
 Specifies the size of the 0MQ thread pool to handle I/O operations. If
 your application is using only the inproc transport for messaging you
@@ -110,17 +470,26 @@ applies before creating any sockets.
 
 Default value   1
 */
-func SetIoThreads(n int) error {
-	return setOption(C.ZMQ_IO_THREADS, n)
+func (ctx *Context) SetIoThreads(n int) error {
+	return ctx.setOption(C.ZMQ_IO_THREADS, n)
 }
 
 /*
-Sets the maximum number of sockets allowed.
+Sets the maximum number of sockets allowed in the default context.
 
 Default value   1024
 */
 func SetMaxSockets(n int) error {
-	return setOption(C.ZMQ_MAX_SOCKETS, n)
+	return defaultCtx.SetMaxSockets(n)
+}
+
+/*
+Sets the maximum number of sockets allowed.
+
+Default value   1024
+*/
+func (ctx *Context) SetMaxSockets(n int) error {
+	return ctx.setOption(C.ZMQ_MAX_SOCKETS, n)
 }
 
 //. Sockets
@@ -266,7 +635,7 @@ func (e Event) String() string {
 	return strings.Join(ee, "|")
 }
 
-// Used by (soc *Socket)GetEvents()
+// Used by (soc *Socket)GetEvents(), and by the Poller and the Reactor
 type State int
 
 const (
@@ -274,6 +643,10 @@ const (
 	// See: http://api.zeromq.org/3-2:zmq-getsockopt#toc24
 	POLLIN  = State(C.ZMQ_POLLIN)
 	POLLOUT = State(C.ZMQ_POLLOUT)
+
+	// Flag for file descriptors added with (*Poller)AddFd(), not used by 0MQ sockets
+	// See: http://api.zeromq.org/3-2:zmq-poll#toc2
+	POLLERR = State(C.ZMQ_POLLERR)
 )
 
 /*
@@ -287,24 +660,90 @@ func (s State) String() string {
 	if s&POLLOUT != 0 {
 		ss = append(ss, "POLLOUT")
 	}
+	if s&POLLERR != 0 {
+		ss = append(ss, "POLLERR")
+	}
 	if len(ss) == 0 {
 		return "<NONE>"
 	}
 	return strings.Join(ss, "|")
 }
 
+const (
+	// Integer options, for (*Socket)SetSockOptInt() and (*Socket)GetSockOptInt()
+	// Some can only be set, such as OPT_ROUTER_MANDATORY, or only be
+	// retrieved, such as OPT_TYPE.
+	// See: http://api.zeromq.org/3-2:zmq-setsockopt
+	// and: http://api.zeromq.org/3-2:zmq-getsockopt
+	OPT_RATE                    = int(C.ZMQ_RATE)
+	OPT_RECOVERY_IVL            = int(C.ZMQ_RECOVERY_IVL)
+	OPT_SNDBUF                  = int(C.ZMQ_SNDBUF)
+	OPT_RCVBUF                  = int(C.ZMQ_RCVBUF)
+	OPT_RCVMORE                 = int(C.ZMQ_RCVMORE)
+	OPT_EVENTS                  = int(C.ZMQ_EVENTS)
+	OPT_TYPE                    = int(C.ZMQ_TYPE)
+	OPT_LINGER                  = int(C.ZMQ_LINGER)
+	OPT_RECONNECT_IVL           = int(C.ZMQ_RECONNECT_IVL)
+	OPT_BACKLOG                 = int(C.ZMQ_BACKLOG)
+	OPT_RECONNECT_IVL_MAX       = int(C.ZMQ_RECONNECT_IVL_MAX)
+	OPT_SNDHWM                  = int(C.ZMQ_SNDHWM)
+	OPT_RCVHWM                  = int(C.ZMQ_RCVHWM)
+	OPT_MULTICAST_HOPS          = int(C.ZMQ_MULTICAST_HOPS)
+	OPT_RCVTIMEO                = int(C.ZMQ_RCVTIMEO)
+	OPT_SNDTIMEO                = int(C.ZMQ_SNDTIMEO)
+	OPT_IPV4ONLY                = int(C.ZMQ_IPV4ONLY)
+	OPT_ROUTER_MANDATORY        = int(C.ZMQ_ROUTER_MANDATORY)
+	OPT_TCP_KEEPALIVE           = int(C.ZMQ_TCP_KEEPALIVE)
+	OPT_TCP_KEEPALIVE_CNT       = int(C.ZMQ_TCP_KEEPALIVE_CNT)
+	OPT_TCP_KEEPALIVE_IDLE      = int(C.ZMQ_TCP_KEEPALIVE_IDLE)
+	OPT_TCP_KEEPALIVE_INTVL     = int(C.ZMQ_TCP_KEEPALIVE_INTVL)
+	OPT_DELAY_ATTACH_ON_CONNECT = int(C.ZMQ_DELAY_ATTACH_ON_CONNECT)
+	OPT_XPUB_VERBOSE            = int(C.ZMQ_XPUB_VERBOSE)
+
+	// Binary options, for (*Socket)SetSockOptString() and (*Socket)GetSockOptString()
+	// OPT_SUBSCRIBE, OPT_UNSUBSCRIBE and OPT_TCP_ACCEPT_FILTER can only be
+	// set, OPT_LAST_ENDPOINT can only be retrieved.
+	OPT_IDENTITY          = int(C.ZMQ_IDENTITY)
+	OPT_SUBSCRIBE         = int(C.ZMQ_SUBSCRIBE)
+	OPT_UNSUBSCRIBE       = int(C.ZMQ_UNSUBSCRIBE)
+	OPT_LAST_ENDPOINT     = int(C.ZMQ_LAST_ENDPOINT)
+	OPT_TCP_ACCEPT_FILTER = int(C.ZMQ_TCP_ACCEPT_FILTER)
+
+	// 64-bit options, not for the functions above: use (*Socket)SetAffinity(),
+	// (*Socket)GetAffinity(), (*Socket)SetMaxmsgsize() and (*Socket)GetMaxmsgsize()
+	OPT_AFFINITY   = int(C.ZMQ_AFFINITY)
+	OPT_MAXMSGSIZE = int(C.ZMQ_MAXMSGSIZE)
+
+	// The file descriptor of the socket, use (*Socket)GetFd()
+	OPT_FD = int(C.ZMQ_FD)
+
+	// ZMQ_IMMEDIATE if defined by zmq.h, else ZMQ_DELAY_ATTACH_ON_CONNECT,
+	// its name before ZeroMQ 3.3
+	OPT_IMMEDIATE = int(C.ZMQ_IMMEDIATE)
+)
+
 /*
 Socket functions starting with `Set` or `Get` are used for setting and
 getting socket options.
 */
 type Socket struct {
-	soc unsafe.Pointer
+	soc     unsafe.Pointer
+	ctx     *Context
+	chans   *socketChannels
+	chansMu sync.Mutex // guards chans
+	tap     *Socket
+	id      uint64 // key in ctx.sockets
+
+	connected     []string // endpoints, for Request()
+	subscriptions []string // filters, for Clone()
+
+	closed uint32 // set atomically by Close
 }
 
 /*
 Socket as string.
 */
-func (soc Socket) String() string {
+func (soc *Socket) String() string {
 	t, _ := soc.GetType()
 	i, err := soc.GetIdentity()
 	if err == nil && i != "" {
@@ -314,7 +753,7 @@ func (soc Socket) String() string {
 }
 
 /*
-Create 0MQ socket.
+Create 0MQ socket in the default context.
 
 WARNING:
 The Socket is not thread safe. This means that you cannot access the same Socket
@@ -323,23 +762,72 @@ from different goroutines without using something like a mutex.
 For a description of socket types, see: http://api.zeromq.org/3-2:zmq-socket#toc3
 */
 func NewSocket(t Type) (soc *Socket, err error) {
-	soc = &Socket{}
-	s, e := C.zmq_socket(ctx, C.int(t))
+	return defaultCtx.NewSocket(t)
+}
+
+/*
+Create 0MQ socket in the given context.
+
+The context keeps track of the socket until it is closed.
+
+On error, the returned socket is nil. If applying the IPv6 setting or a
+default socket option fails, the new socket is closed first.
+
+See: func NewSocket
+*/
+func (ctx *Context) NewSocket(t Type) (soc *Socket, err error) {
+	if !ctx.opened {
+		return nil, ErrContextClosed
+	}
+	s, e := C.zmq_socket(ctx.ctx, C.int(t))
 	if s == nil {
-		err = errget(e)
-	} else {
-		soc.soc = s
-		runtime.SetFinalizer(soc, (*Socket).Close)
+		return nil, errget(e)
 	}
-	return
+	soc = &Socket{soc: s, ctx: ctx}
+	ctx.addSocket(soc)
+	runtime.SetFinalizer(soc, (*Socket).Close)
+	if err = ctx.initSocket(soc); err != nil {
+		soc.Close()
+		return nil, err
+	}
+	return soc, nil
 }
 
-// If not called explicitly, the socket will be closed on garbage collection
+// Apply the IPv6 setting and the default socket options of the context to a new socket.
+func (ctx *Context) initSocket(soc *Socket) error {
+	if ctx.GetIpv6() {
+		if err := soc.SetIpv4only(false); err != nil {
+			return err
+		}
+	}
+	ctx.mu.Lock()
+	defaults := append([]sockOptDefault(nil), ctx.defaults...)
+	ctx.mu.Unlock()
+	for _, d := range defaults {
+		if err := soc.SetSockOptInt(d.opt, d.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close the socket.
+//
+// Sockets that are still open are closed when their context is terminated.
+//
+// It is safe to call Close more than once, also from different goroutines.
+// Only the first call closes the socket, later calls return nil.
 func (soc *Socket) Close() error {
-	if i, err := C.zmq_close(soc.soc); int(i) != 0 {
-		return errget(err)
+	if !atomic.CompareAndSwapUint32(&soc.closed, 0, 1) || soc.soc == nil {
+		return nil
 	}
+	defer runtime.KeepAlive(soc)
+	i, err := C.zmq_close(soc.soc)
 	soc.soc = unsafe.Pointer(nil)
+	soc.ctx.removeSocket(soc)
+	if int(i) != 0 {
+		return errget(err)
+	}
 	return nil
 }
 
@@ -349,6 +837,10 @@ Accept incoming connections on a socket.
 For a description of endpoint, see: http://api.zeromq.org/3-2:zmq-bind#toc2
 */
 func (soc *Socket) Bind(endpoint string) error {
+	if soc.soc == nil {
+		return ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	s := C.CString(endpoint)
 	defer C.free(unsafe.Pointer(s))
 	if i, err := C.zmq_bind(soc.soc, s); int(i) != 0 {
@@ -360,9 +852,17 @@ func (soc *Socket) Bind(endpoint string) error {
 /*
 Stop accepting connections on a socket.
 
+After binding to a wildcard address, such as "tcp://*:*", use the
+endpoint returned by GetLastEndpoint() to unbind. If the socket was not
+bound to the endpoint, the error is ENOENT.
+
 For a description of endpoint, see: http://api.zeromq.org/3-2:zmq-bind#toc2
 */
 func (soc *Socket) Unbind(endpoint string) error {
+	if soc.soc == nil {
+		return ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	s := C.CString(endpoint)
 	defer C.free(unsafe.Pointer(s))
 	if i, err := C.zmq_unbind(soc.soc, s); int(i) != 0 {
@@ -377,31 +877,69 @@ Create outgoing connection from socket.
 For a description of endpoint, see: http://api.zeromq.org/3-2:zmq-connect#toc2
 */
 func (soc *Socket) Connect(endpoint string) error {
+	if soc.soc == nil {
+		return ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	s := C.CString(endpoint)
 	defer C.free(unsafe.Pointer(s))
 	if i, err := C.zmq_connect(soc.soc, s); int(i) != 0 {
 		return errget(err)
 	}
+	soc.connected = append(soc.connected, endpoint)
 	return nil
 }
 
 /*
 Disconnect a socket.
 
+The endpoint must be exactly the same as was used with Connect(). If the
+socket was not connected to the endpoint, the error is ENOENT.
+
 For a description of endpoint, see: http://api.zeromq.org/3-2:zmq-connect#toc2
 */
 func (soc *Socket) Disconnect(endpoint string) error {
+	if soc.soc == nil {
+		return ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	s := C.CString(endpoint)
 	defer C.free(unsafe.Pointer(s))
 	if i, err := C.zmq_disconnect(soc.soc, s); int(i) != 0 {
 		return errget(err)
 	}
+	for i, e := range soc.connected {
+		if e == endpoint {
+			soc.connected = append(soc.connected[:i], soc.connected[i+1:]...)
+			break
+		}
+	}
 	return nil
 }
 
+// Should a call interrupted by a signal be restarted?
+// Is the error one after which the socket can't be used anymore, because it
+// was closed or its context was terminated?
+func isFatal(err error) bool {
+	return err == ErrETERM || err == ErrSocketClosed || errors.Is(err, syscall.Errno(C.ENOTSOCK))
+}
+
+func (soc *Socket) retry(err error, flags Flag) bool {
+	if flags&DONTWAIT != 0 || errget(err) != ErrEINTR {
+		return false
+	}
+	soc.ctx.mu.Lock()
+	defer soc.ctx.mu.Unlock()
+	return soc.ctx.retryEINTR
+}
+
 /*
 Receive a message part from a socket.
 
+An empty string with a nil error is a valid empty message part.
+To find out whether more parts follow, use GetRcvmore(), or use
+RecvInto() or RecvMessage() instead.
+
 For a description of flags, see: http://api.zeromq.org/3-2:zmq-msg-recv#toc2
 */
 func (soc *Socket) Recv(flags Flag) (string, error) {
@@ -412,9 +950,18 @@ func (soc *Socket) Recv(flags Flag) (string, error) {
 /*
 Receive a message part from a socket.
 
+An empty, non-nil slice with a nil error is a valid empty message part.
+On error, the slice is empty as well, so always check the error first.
+To find out whether more parts follow, use GetRcvmore(), or use
+RecvInto() or RecvMessageBytes() instead.
+
 For a description of flags, see: http://api.zeromq.org/3-2:zmq-msg-recv#toc2
 */
 func (soc *Socket) RecvBytes(flags Flag) ([]byte, error) {
+	if soc.soc == nil {
+		return []byte{}, ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	var msg C.zmq_msg_t
 	if i, err := C.zmq_msg_init(&msg); i != 0 {
 		return []byte{}, errget(err)
@@ -422,17 +969,87 @@ func (soc *Socket) RecvBytes(flags Flag) ([]byte, error) {
 	defer C.zmq_msg_close(&msg)
 
 	size, err := C.zmq_msg_recv(&msg, soc.soc, C.int(flags))
+	for size < 0 && soc.retry(err, flags) {
+		size, err = C.zmq_msg_recv(&msg, soc.soc, C.int(flags))
+	}
 	if size < 0 {
 		return []byte{}, errget(err)
 	}
 	if size == 0 {
+		soc.tapped([]byte{})
 		return []byte{}, nil
 	}
 	data := make([]byte, int(size))
 	C.memcpy(unsafe.Pointer(&data[0]), C.zmq_msg_data(&msg), C.size_t(size))
+	soc.tapped(data)
 	return data, nil
 }
 
+/*
+Receive a message part from a socket into a buffer.
+
+Returns the size of the message part, whether it was truncated, and
+whether more parts will follow. If the message part is larger than the
+buffer, only len(buf) bytes are copied, the rest is discarded, and
+truncated is true. The size n is always the full size of the message
+part, so the caller can use a larger buffer for the next message.
+
+For a description of flags, see: http://api.zeromq.org/3-2:zmq-msg-recv#toc2
+*/
+func (soc *Socket) RecvInto(buf []byte, flags Flag) (n int, truncated bool, more bool, err error) {
+	if soc.soc == nil {
+		return 0, false, false, ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
+	b := buf
+	if len(buf) == 0 {
+		b = []byte{0}
+	}
+	size, e := C.zmq_recv(soc.soc, unsafe.Pointer(&b[0]), C.size_t(len(buf)), C.int(flags))
+	for size < 0 && soc.retry(e, flags) {
+		size, e = C.zmq_recv(soc.soc, unsafe.Pointer(&b[0]), C.size_t(len(buf)), C.int(flags))
+	}
+	if size < 0 {
+		return 0, false, false, errget(e)
+	}
+	n = int(size)
+	truncated = n > len(buf)
+	more, err = soc.GetRcvmore()
+	if soc.tap != nil {
+		if truncated {
+			soc.tapped(buf)
+		} else {
+			soc.tapped(buf[:n])
+		}
+	}
+	return
+}
+
+/*
+Forward a copy of each message part received to the capture socket.
+
+Applies to Recv(), RecvBytes(), RecvInto(), and the functions using these.
+Message parts are sent on the capture socket with DONTWAIT, so they are
+dropped instead of blocking the socket when the capture socket can't
+keep up. Both sockets should be used in the same goroutine.
+
+Use nil to stop forwarding.
+*/
+func (soc *Socket) Tap(capture *Socket) {
+	soc.tap = capture
+}
+
+func (soc *Socket) tapped(data []byte) {
+	if soc.tap == nil {
+		return
+	}
+	flags := DONTWAIT
+	if more, _ := soc.GetRcvmore(); more {
+		flags |= SNDMORE
+	}
+	soc.tap.SendBytes(data, flags)
+}
+
 /*
 Send a message part on a socket.
 
@@ -448,11 +1065,18 @@ Send a message part on a socket.
 For a description of flags, see: http://api.zeromq.org/3-2:zmq-send#toc2
 */
 func (soc *Socket) SendBytes(data []byte, flags Flag) (int, error) {
+	if soc.soc == nil {
+		return -1, ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
 	d := data
 	if len(data) == 0 {
 		d = []byte{0}
 	}
 	size, err := C.zmq_send(soc.soc, unsafe.Pointer(&d[0]), C.size_t(len(data)), C.int(flags))
+	for size < 0 && soc.retry(err, flags) {
+		size, err = C.zmq_send(soc.soc, unsafe.Pointer(&d[0]), C.size_t(len(data)), C.int(flags))
+	}
 	if size < 0 {
 		return int(size), errget(err)
 	}
@@ -462,6 +1086,8 @@ func (soc *Socket) SendBytes(data []byte, flags Flag) (int, error) {
 /*
 Register a monitoring callback.
 
+A socket can have only one monitor. Use an empty addr to stop monitoring.
+
 See: http://api.zeromq.org/3-2:zmq-socket-monitor#toc2
 
 Example:
@@ -520,8 +1146,15 @@ Example:
     }
 */
 func (soc *Socket) Monitor(addr string, events Event) error {
-	s := C.CString(addr)
-	defer C.free(unsafe.Pointer(s))
+	if soc.soc == nil {
+		return ErrSocketClosed
+	}
+	defer runtime.KeepAlive(soc)
+	var s *C.char
+	if addr != "" {
+		s = C.CString(addr)
+		defer C.free(unsafe.Pointer(s))
+	}
 	if i, err := C.zmq_socket_monitor(soc.soc, s, C.int(events)); i != 0 {
 		return errget(err)
 	}
@@ -538,6 +1171,11 @@ For a description of event_type, see: http://api.zeromq.org/3-2:zmq-socket-monit
 For an example, see: func (*Socket) Monitor
 */
 func (soc *Socket) RecvEvent(flags Flag) (event_type Event, addr string, value int, err error) {
+	if soc.soc == nil {
+		err = ErrSocketClosed
+		return
+	}
+	defer runtime.KeepAlive(soc)
 	var msg C.zmq_msg_t
 	if i, e := C.zmq_msg_init(&msg); i != 0 {
 		err = errget(e)
@@ -546,6 +1184,9 @@ func (soc *Socket) RecvEvent(flags Flag) (event_type Event, addr string, value i
 	defer C.zmq_msg_close(&msg)
 
 	size, e := C.zmq_msg_recv(&msg, soc.soc, C.int(flags))
+	for size < 0 && soc.retry(e, flags) {
+		size, e = C.zmq_msg_recv(&msg, soc.soc, C.int(flags))
+	}
 	if size < 0 {
 		err = errget(e)
 		return
@@ -569,16 +1210,73 @@ func (soc *Socket) RecvEvent(flags Flag) (event_type Event, addr string, value i
 	return
 }
 
+/*
+Create a PAIR socket in the default context, connected to the monitor endpoint.
+
+See: func (*Context) NewMonitorSocket
+*/
+func NewMonitorSocket(endpoint string) (*Socket, error) {
+	return defaultCtx.NewMonitorSocket(endpoint)
+}
+
+/*
+Create a PAIR socket in the given context, connected to the monitor
+endpoint, for receiving events with RecvEvent().
+
+The endpoint is the addr given to Monitor(), which must be called first:
+an inproc endpoint must be bound before a socket can connect to it. The
+context must be the context of the monitored socket. Linger is set to
+zero, so closing the monitor socket never blocks.
+
+Example:
+
+    rep.Monitor("inproc://monitor.rep", zmq.EVENT_ALL)
+    mon, err := zmq.NewMonitorSocket("inproc://monitor.rep")
+    if err != nil {
+        log.Fatalln(err)
+    }
+    defer mon.Close()
+    for {
+        event, addr, value, err := mon.RecvEvent(0)
+        //  Process event
+    }
+*/
+func (ctx *Context) NewMonitorSocket(endpoint string) (*Socket, error) {
+	mon, err := ctx.NewSocket(PAIR)
+	if err != nil {
+		return nil, err
+	}
+	mon.SetLinger(0)
+	if err := mon.Connect(endpoint); err != nil {
+		mon.Close()
+		return nil, err
+	}
+	return mon, nil
+}
+
 /*
 Start built-in ØMQ proxy
 
+The capture socket is optional, use nil if there is none.
+
+The proxy runs until the context is terminated, and then returns ETERM.
+
 See: http://api.zeromq.org/3-2:zmq-proxy
 */
 func Proxy(frontend, backend, capture *Socket) error {
+	if frontend.soc == nil || backend.soc == nil {
+		return ErrSocketClosed
+	}
 	var capt unsafe.Pointer
 	if capture != nil {
+		if capture.soc == nil {
+			return ErrSocketClosed
+		}
 		capt = capture.soc
 	}
+	defer runtime.KeepAlive(frontend)
+	defer runtime.KeepAlive(backend)
+	defer runtime.KeepAlive(capture)
 	_, err := C.zmq_proxy(frontend.soc, backend.soc, capt)
 	return errget(err)
 }
@@ -0,0 +1,1747 @@
+package zmq3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestEmptyFrame(t *testing.T) {
+
+	sb, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sb.Close()
+	sc, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sc.Close()
+
+	err = sb.Bind("inproc://empty")
+	if err != nil {
+		t.Fatal("sb.Bind:", err)
+	}
+	err = sc.Connect("inproc://empty")
+	if err != nil {
+		t.Fatal("sc.Connect:", err)
+	}
+
+	_, err = sc.SendBytes([]byte{}, SNDMORE)
+	if err != nil {
+		t.Fatal("sc.SendBytes empty:", err)
+	}
+	_, err = sc.SendBytes([]byte("data"), 0)
+	if err != nil {
+		t.Fatal("sc.SendBytes data:", err)
+	}
+
+	msg, err := sb.RecvMessageBytes(0)
+	if err != nil {
+		t.Fatal("sb.RecvMessageBytes:", err)
+	}
+	if len(msg) != 2 || len(msg[0]) != 0 || string(msg[1]) != "data" {
+		t.Errorf("Expected [\"\" \"data\"], got %q", msg)
+	}
+}
+
+func TestSndtimeo(t *testing.T) {
+
+	push, err := NewSocket(PUSH)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer push.Close()
+
+	err = push.SetSndtimeo(100 * time.Millisecond)
+	if err != nil {
+		t.Fatal("SetSndtimeo:", err)
+	}
+	err = push.Bind("inproc://sndtimeo")
+	if err != nil {
+		t.Fatal("Bind:", err)
+	}
+
+	// no PULL socket connected, so this can't be delivered
+	start := time.Now()
+	_, err = push.Send("message", 0)
+	if err != ErrEAGAIN {
+		t.Errorf("Expected EAGAIN, got %v", err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("Send returned too soon, after %v", d)
+	}
+}
+
+func TestGetEvents(t *testing.T) {
+
+	sb, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sb.Close()
+	sc, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sc.Close()
+
+	err = sb.Bind("inproc://events")
+	if err != nil {
+		t.Fatal("sb.Bind:", err)
+	}
+	err = sc.Connect("inproc://events")
+	if err != nil {
+		t.Fatal("sc.Connect:", err)
+	}
+
+	state, err := sb.GetEvents()
+	if err != nil {
+		t.Fatal("sb.GetEvents:", err)
+	}
+	if state&POLLIN != 0 {
+		t.Errorf("Expected no POLLIN before sending, got %v", state)
+	}
+
+	_, err = sc.Send("hello", 0)
+	if err != nil {
+		t.Fatal("sc.Send:", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		state, err = sb.GetEvents()
+		if err != nil {
+			t.Fatal("sb.GetEvents:", err)
+		}
+		if state&POLLIN != 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if state&POLLIN == 0 {
+		t.Errorf("Expected POLLIN after sending, got %v", state)
+	}
+}
+
+func TestGetType(t *testing.T) {
+
+	for _, typ := range []Type{REQ, REP, DEALER, ROUTER, PUB, SUB, XPUB, XSUB, PUSH, PULL, PAIR} {
+		soc, err := NewSocket(typ)
+		if err != nil {
+			t.Fatal("NewSocket:", err)
+		}
+		tt, err := soc.GetType()
+		if err != nil {
+			t.Error("GetType:", err)
+		} else if tt != typ {
+			t.Errorf("Expected %v, got %v", typ, tt)
+		}
+		soc.Close()
+	}
+}
+
+func TestRouterMandatory(t *testing.T) {
+
+	router, err := NewSocket(ROUTER)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer router.Close()
+
+	err = router.SetRouterMandatory(true)
+	if err != nil {
+		t.Fatal("SetRouterMandatory:", err)
+	}
+	err = router.Bind("inproc://mandatory")
+	if err != nil {
+		t.Fatal("Bind:", err)
+	}
+
+	_, err = router.SendMessage("bogus", "data")
+	if err != syscall.EHOSTUNREACH {
+		t.Errorf("Expected EHOSTUNREACH, got %v", err)
+	}
+}
+
+func TestXpubVerbose(t *testing.T) {
+
+	xpub, err := NewSocket(XPUB)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer xpub.Close()
+
+	err = xpub.SetXpubVerbose(true)
+	if err != nil {
+		t.Fatal("SetXpubVerbose:", err)
+	}
+	err = xpub.SetRcvtimeo(time.Second)
+	if err != nil {
+		t.Fatal("SetRcvtimeo:", err)
+	}
+	err = xpub.Bind("inproc://verbose")
+	if err != nil {
+		t.Fatal("Bind:", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		sub, err := NewSocket(SUB)
+		if err != nil {
+			t.Fatal("NewSocket:", err)
+		}
+		defer sub.Close()
+		err = sub.Connect("inproc://verbose")
+		if err != nil {
+			t.Fatal("Connect:", err)
+		}
+		err = sub.SetSubscribe("topic")
+		if err != nil {
+			t.Fatal("SetSubscribe:", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		s, err := xpub.Recv(0)
+		if err != nil {
+			t.Fatalf("Recv subscription %d: %v", i+1, err)
+		}
+		if s != "\x01topic" {
+			t.Errorf("Expected subscription \"\\x01topic\", got %q", s)
+		}
+	}
+}
+
+func TestBacklog(t *testing.T) {
+
+	soc, err := NewSocket(REP)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer soc.Close()
+
+	err = soc.SetBacklog(500)
+	if err != nil {
+		t.Fatal("SetBacklog:", err)
+	}
+	n, err := soc.GetBacklog()
+	if err != nil {
+		t.Fatal("GetBacklog:", err)
+	}
+	if n != 500 {
+		t.Errorf("Expected backlog 500, got %d", n)
+	}
+}
+
+func TestAffinity(t *testing.T) {
+
+	soc, err := NewSocket(PUSH)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer soc.Close()
+
+	var mask uint64 = 1<<63 | 1<<3 | 1
+	err = soc.SetAffinity(mask)
+	if err != nil {
+		t.Fatal("SetAffinity:", err)
+	}
+	v, err := soc.GetAffinity()
+	if err != nil {
+		t.Fatal("GetAffinity:", err)
+	}
+	if v != mask {
+		t.Errorf("Expected affinity %#x, got %#x", mask, v)
+	}
+}
+
+func TestSendEmpty(t *testing.T) {
+
+	sb, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sb.Close()
+	sc, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sc.Close()
+
+	err = sb.Bind("inproc://sendempty")
+	if err != nil {
+		t.Fatal("sb.Bind:", err)
+	}
+	err = sc.Connect("inproc://sendempty")
+	if err != nil {
+		t.Fatal("sc.Connect:", err)
+	}
+
+	n, err := sc.SendBytes([]byte{}, 0)
+	if n != 0 || err != nil {
+		t.Errorf("SendBytes empty: expected (0, <nil>), got (%d, %v)", n, err)
+	}
+	n, err = sc.SendBytes(nil, 0)
+	if n != 0 || err != nil {
+		t.Errorf("SendBytes nil: expected (0, <nil>), got (%d, %v)", n, err)
+	}
+	n, err = sc.Send("", 0)
+	if n != 0 || err != nil {
+		t.Errorf("Send empty: expected (0, <nil>), got (%d, %v)", n, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		b, err := sb.RecvBytes(0)
+		if err != nil {
+			t.Fatal("sb.RecvBytes:", err)
+		}
+		if len(b) != 0 {
+			t.Errorf("Expected empty message, got %q", b)
+		}
+	}
+}
+
+func TestStateString(t *testing.T) {
+
+	for _, c := range []struct {
+		state State
+		s     string
+	}{
+		{0, "<NONE>"},
+		{POLLIN, "POLLIN"},
+		{POLLOUT, "POLLOUT"},
+		{POLLERR, "POLLERR"},
+		{POLLIN | POLLOUT, "POLLIN|POLLOUT"},
+		{POLLIN | POLLOUT | POLLERR, "POLLIN|POLLOUT|POLLERR"},
+	} {
+		if s := c.state.String(); s != c.s {
+			t.Errorf("Expected %q, got %q", c.s, s)
+		}
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+
+	for _, c := range []struct {
+		endpoint  string
+		transport string
+		address   string
+		ok        bool
+	}{
+		{"tcp://127.0.0.1:5555", "tcp", "127.0.0.1:5555", true},
+		{"tcp://*:*", "tcp", "*:*", true},
+		{"ipc:///tmp/socket", "ipc", "/tmp/socket", true},
+		{"inproc://workers", "inproc", "workers", true},
+		{"epgm://eth0;239.192.1.1:5555", "epgm", "eth0;239.192.1.1:5555", true},
+		{"pgm://eth0;239.192.1.1:*", "", "", false},
+		{"tcp://localhost", "", "", false},
+		{"tcp://localhost:port", "", "", false},
+		{"inproc://", "", "", false},
+		{"udp://localhost:5555", "", "", false},
+		{"localhost:5555", "", "", false},
+	} {
+		transport, address, err := ParseEndpoint(c.endpoint)
+		if c.ok && err != nil {
+			t.Errorf("ParseEndpoint(%q): %v", c.endpoint, err)
+		} else if !c.ok && err == nil {
+			t.Errorf("ParseEndpoint(%q): expected error", c.endpoint)
+		} else if transport != c.transport || address != c.address {
+			t.Errorf("ParseEndpoint(%q): expected %q %q, got %q %q", c.endpoint, c.transport, c.address, transport, address)
+		}
+	}
+}
+
+func TestImmediate(t *testing.T) {
+
+	// find a free port, with nothing bound to it
+	tmp, err := NewSocket(PULL)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	port, err := tmp.BindTCP("127.0.0.1", 0)
+	tmp.Close()
+	if err != nil {
+		t.Fatal("BindTCP:", err)
+	}
+	endpoint := fmt.Sprintf("tcp://127.0.0.1:%d", port)
+
+	for _, immediate := range []bool{false, true} {
+		push, err := NewSocket(PUSH)
+		if err != nil {
+			t.Fatal("NewSocket:", err)
+		}
+		push.SetLinger(0)
+		err = push.SetImmediate(immediate)
+		if err != nil {
+			t.Fatal("SetImmediate:", err)
+		}
+		v, err := push.GetImmediate()
+		if err != nil {
+			t.Fatal("GetImmediate:", err)
+		}
+		if v != immediate {
+			t.Errorf("Expected immediate %v, got %v", immediate, v)
+		}
+		err = push.Connect(endpoint)
+		if err != nil {
+			t.Fatal("Connect:", err)
+		}
+
+		// without a peer, the message is only queued if immediate is false
+		_, err = push.Send("message", DONTWAIT)
+		if immediate && err != ErrEAGAIN {
+			t.Errorf("With immediate, expected EAGAIN, got %v", err)
+		}
+		if !immediate && err != nil {
+			t.Errorf("Without immediate, expected message to be queued, got %v", err)
+		}
+		push.Close()
+	}
+}
+
+func TestSocketGC(t *testing.T) {
+
+	ctx, err := NewContext()
+	if err != nil {
+		t.Fatal("NewContext:", err)
+	}
+	defer ctx.Term()
+
+	for i := 0; i < 10; i++ {
+		if _, err := ctx.NewSocket(PAIR); err != nil {
+			t.Fatal("NewSocket:", err)
+		}
+	}
+	kept, err := ctx.NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer kept.Close()
+
+	n := 0
+	for i := 0; i < 100; i++ {
+		runtime.GC()
+		ctx.mu.Lock()
+		n = len(ctx.sockets)
+		ctx.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 socket tracked after garbage collection, got %d", n)
+	}
+}
+
+func TestHeartbeaterDead(t *testing.T) {
+
+	dealer, err := NewSocket(DEALER)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer dealer.Close()
+	dealer.SetLinger(0)
+
+	// no peer, so nothing can be sent, and nothing is received
+	err = dealer.Connect("tcp://127.0.0.1:1")
+	if err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	hb := NewHeartbeater(dealer, 10*time.Millisecond, 100*time.Millisecond, []byte("PING"))
+	select {
+	case hb.Send() <- [][]byte{[]byte("hello")}:
+	case <-time.After(time.Second):
+		t.Fatal("Send blocked")
+	}
+	select {
+	case <-hb.Dead():
+	case <-time.After(time.Second):
+		t.Error("Dead not signalled")
+	}
+
+	stopped := make(chan bool)
+	go func() {
+		hb.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked")
+	}
+
+	// stopping again is harmless
+	hb.Stop()
+}
+
+func TestChannels(t *testing.T) {
+
+	a, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer a.Close()
+	b, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer b.Close()
+	if err := a.Bind("inproc://channels"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+	if err := b.Connect("inproc://channels"); err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	a.SendChannel() <- []byte("ping")
+	select {
+	case msg := <-b.RecvChannel():
+		if string(msg) != "ping" {
+			t.Errorf("b received %q, expected \"ping\"", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("b received nothing")
+	}
+	b.SendChannel() <- []byte("pong")
+	select {
+	case msg := <-a.RecvChannel():
+		if string(msg) != "pong" {
+			t.Errorf("a received %q, expected \"pong\"", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a received nothing")
+	}
+
+	recvs := []<-chan []byte{a.RecvChannel(), b.RecvChannel()}
+	close(a.SendChannel())
+	close(b.SendChannel())
+	for _, recv := range recvs {
+		select {
+		case _, ok := <-recv:
+			if ok {
+				t.Error("received after closing the send channel")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("receive channel not closed")
+		}
+	}
+
+	// new channels after the old ones are closed
+	a.SendChannel() <- []byte("again")
+	select {
+	case msg := <-b.RecvChannel():
+		if string(msg) != "again" {
+			t.Errorf("b received %q, expected \"again\"", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("b received nothing on new channels")
+	}
+	recvs = []<-chan []byte{a.RecvChannel(), b.RecvChannel()}
+	close(a.SendChannel())
+	close(b.SendChannel())
+	for _, recv := range recvs {
+		for range recv {
+		}
+	}
+}
+
+func TestWaitForConnectInproc(t *testing.T) {
+
+	server, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer server.Close()
+	client, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer client.Close()
+	if err := server.Bind("inproc://waitforconnect"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+	if err := client.WaitForConnect("inproc://waitforconnect", 100*time.Millisecond); err != nil {
+		t.Fatal("WaitForConnect:", err)
+	}
+	if _, err := client.Send("hello", 0); err != nil {
+		t.Fatal("Send:", err)
+	}
+	server.SetRcvtimeo(time.Second)
+	if msg, err := server.Recv(0); err != nil || msg != "hello" {
+		t.Errorf("Recv: %q, %v", msg, err)
+	}
+}
+
+func TestConnectWaitInproc(t *testing.T) {
+
+	server, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer server.Close()
+	client, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer client.Close()
+	if err := server.Bind("inproc://connectwait"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+	if err := client.ConnectWait("inproc://connectwait", 100*time.Millisecond); err != nil {
+		t.Fatal("ConnectWait:", err)
+	}
+	// still connected
+	if _, err := client.Send("hello", 0); err != nil {
+		t.Fatal("Send:", err)
+	}
+	server.SetRcvtimeo(time.Second)
+	if msg, err := server.Recv(0); err != nil || msg != "hello" {
+		t.Errorf("Recv: %q, %v", msg, err)
+	}
+}
+
+func TestNewSocketDefaultFails(t *testing.T) {
+
+	ctx, err := NewContext()
+	if err != nil {
+		t.Fatal("NewContext:", err)
+	}
+	defer ctx.Term()
+
+	// not integer options for every socket type
+	for _, opt := range []int{-1, OPT_AFFINITY, OPT_MAXMSGSIZE, OPT_IDENTITY, OPT_SUBSCRIBE, OPT_TYPE, OPT_XPUB_VERBOSE} {
+		if err := ctx.SetDefaultSockOpt(opt, 0); err == nil {
+			t.Errorf("Expected error from SetDefaultSockOpt for option %d", opt)
+		}
+	}
+	if _, err := ctx.NewSocket(PAIR); err != nil {
+		t.Fatal("NewSocket with rejected defaults:", err)
+	}
+
+	// an invalid value
+	if err := ctx.SetDefaultSockOpt(OPT_SNDHWM, -1); err != nil {
+		t.Fatal("SetDefaultSockOpt:", err)
+	}
+	soc, err := ctx.NewSocket(PAIR)
+	if err == nil {
+		t.Fatal("Expected error from NewSocket")
+	}
+	if soc != nil {
+		t.Error("Expected nil socket with error")
+	}
+	ctx.mu.Lock()
+	n := len(ctx.sockets)
+	ctx.mu.Unlock()
+	if n != 1 {
+		t.Errorf("Expected 1 socket tracked, got %d", n)
+	}
+
+	ctx.Term()
+	if soc, err := ctx.NewSocket(PAIR); err != ErrContextClosed || soc != nil {
+		t.Errorf("Expected nil and ErrContextClosed after Term, got %v, %v", soc, err)
+	}
+}
+
+func TestRequestReopenDefaults(t *testing.T) {
+
+	ctx, err := NewContext()
+	if err != nil {
+		t.Fatal("NewContext:", err)
+	}
+	defer ctx.Term()
+	ctx.SetDefaultSockOpt(OPT_SNDHWM, 123)
+
+	client, err := ctx.NewSocket(REQ)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer client.Close()
+	client.SetSndhwm(50)
+	if err := client.Connect("tcp://127.0.0.1:9997"); err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	// nobody answers, so the socket is reopened
+	if _, err := client.Request([][]byte{[]byte("hello")}, 10*time.Millisecond, 0); err == nil {
+		t.Fatal("Expected error from Request without server")
+	}
+	hwm, err := client.GetSndhwm()
+	if err != nil {
+		t.Fatal("GetSndhwm:", err)
+	}
+	if hwm != 123 {
+		t.Errorf("Expected default sndhwm 123 after reopen, got %d", hwm)
+	}
+}
+
+func TestErrnoAs(t *testing.T) {
+
+	soc, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer soc.Close()
+
+	_, err = soc.RecvBytes(DONTWAIT)
+	var e Errno
+	if !errors.As(err, &e) || e != ErrEAGAIN {
+		t.Errorf("Expected Errno EAGAIN, got %#v", err)
+	}
+	var se syscall.Errno
+	if !errors.As(err, &se) || se != syscall.EAGAIN {
+		t.Errorf("Expected syscall.Errno EAGAIN, got %#v", err)
+	}
+}
+
+func TestErrnoIs(t *testing.T) {
+
+	soc, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer soc.Close()
+
+	_, err = soc.RecvBytes(DONTWAIT)
+	if !errors.Is(err, ErrEAGAIN) {
+		t.Errorf("Expected errors.Is(err, ErrEAGAIN), got %#v", err)
+	}
+	if !errors.Is(err, syscall.EAGAIN) {
+		t.Errorf("Expected errors.Is(err, syscall.EAGAIN), got %#v", err)
+	}
+	if errors.Is(err, ErrEINTR) || errors.Is(err, syscall.EINTR) {
+		t.Errorf("EAGAIN matches EINTR")
+	}
+	wrapped := fmt.Errorf("recv: %w", err)
+	if !errors.Is(wrapped, syscall.EAGAIN) {
+		t.Errorf("Expected wrapped error to match syscall.EAGAIN")
+	}
+}
+
+func TestBindTCPIpv6(t *testing.T) {
+
+	soc, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer soc.Close()
+	if err := soc.SetIpv4only(false); err != nil {
+		t.Fatal("SetIpv4only:", err)
+	}
+
+	port, err := soc.BindTCP("::1", 0)
+	if err != nil {
+		t.Fatal("BindTCP:", err)
+	}
+	if port <= 0 {
+		t.Errorf("Expected a port, got %d", port)
+	}
+}
+
+func TestPollerAddFd(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("AddFd only works with sockets on Windows")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal("Pipe:", err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	soc, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer soc.Close()
+
+	poller := NewPoller()
+	if id := poller.Add(soc, POLLIN); id != 0 {
+		t.Errorf("Expected id 0 for socket, got %d", id)
+	}
+	if id := poller.AddFd(r.Fd(), POLLIN); id != 1 {
+		t.Errorf("Expected id 1 for fd, got %d", id)
+	}
+
+	polled, err := poller.Poll(0)
+	if err != nil {
+		t.Fatal("Poll:", err)
+	}
+	if len(polled) != 0 {
+		t.Errorf("Expected nothing ready, got %v", polled)
+	}
+
+	if _, err := w.Write([]byte("x")); err != nil {
+		t.Fatal("Write:", err)
+	}
+	polled, err = poller.Poll(time.Second)
+	if err != nil {
+		t.Fatal("Poll:", err)
+	}
+	if len(polled) != 1 {
+		t.Fatalf("Expected 1 item ready, got %v", polled)
+	}
+	if polled[0].Socket != nil || polled[0].Fd != r.Fd() || polled[0].Events&POLLIN == 0 {
+		t.Errorf("Expected fd %d with POLLIN, got %+v", r.Fd(), polled[0])
+	}
+
+	// file descriptors are left out of PollMap
+	m, err := poller.PollMap(0)
+	if err != nil {
+		t.Fatal("PollMap:", err)
+	}
+	if len(m) != 0 {
+		t.Errorf("Expected empty map, got %v", m)
+	}
+}
+
+func TestPollerRemoveUpdate(t *testing.T) {
+
+	socs := make([]*Socket, 3)
+	for i := range socs {
+		soc, err := NewSocket(PAIR)
+		if err != nil {
+			t.Fatal("NewSocket:", err)
+		}
+		defer soc.Close()
+		socs[i] = soc
+	}
+	if err := socs[0].Bind("inproc://pollerupdate"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+	if err := socs[2].Connect("inproc://pollerupdate"); err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	poller := NewPoller()
+	for i, soc := range socs {
+		if id := poller.Add(soc, POLLIN); id != i {
+			t.Errorf("Expected id %d, got %d", i, id)
+		}
+	}
+
+	if err := poller.Remove(socs[1]); err != nil {
+		t.Fatal("Remove:", err)
+	}
+	if err := poller.Remove(socs[1]); err != errNotPolled {
+		t.Errorf("Expected errNotPolled from second Remove, got %v", err)
+	}
+	if err := poller.Update(socs[1], POLLOUT); err != errNotPolled {
+		t.Errorf("Expected errNotPolled from Update after Remove, got %v", err)
+	}
+
+	// ids of later items have shifted down
+	expected := fmt.Sprint("Poller", []string{
+		fmt.Sprintf("%v%v", socs[0], POLLIN),
+		fmt.Sprintf("%v%v", socs[2], POLLIN),
+	})
+	if s := poller.String(); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+	if id := poller.Add(socs[1], POLLIN); id != 2 {
+		t.Errorf("Expected id 2 when adding again, got %d", id)
+	}
+
+	if err := poller.Update(socs[0], POLLOUT); err != nil {
+		t.Fatal("Update:", err)
+	}
+	polled, err := poller.Poll(time.Second)
+	if err != nil {
+		t.Fatal("Poll:", err)
+	}
+	if len(polled) != 1 || polled[0].Socket != socs[0] || polled[0].Events&POLLOUT == 0 {
+		t.Errorf("Expected socket 0 ready for POLLOUT, got %v", polled)
+	}
+}
+
+func TestSendZeroCopy(t *testing.T) {
+
+	sb, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sb.Close()
+	sc, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sc.Close()
+
+	// nobody connected, so this fails, and free is called anyway
+	freed := make(chan bool)
+	if _, err := sc.SendZeroCopy([]byte("lost"), func() { close(freed) }, DONTWAIT); err != ErrEAGAIN {
+		t.Errorf("Expected EAGAIN, got %v", err)
+	}
+	select {
+	case <-freed:
+	case <-time.After(time.Second):
+		t.Error("free not called after failed send")
+	}
+
+	if err := sb.Bind("inproc://zerocopy"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+	if err := sc.Connect("inproc://zerocopy"); err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	data := make([]byte, 100000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	freed = make(chan bool)
+	n, err := sc.SendZeroCopy(data, func() { close(freed) }, 0)
+	if err != nil {
+		t.Fatal("SendZeroCopy:", err)
+	}
+	if n != len(data) {
+		t.Errorf("Expected %d bytes sent, got %d", len(data), n)
+	}
+	// let the garbage collector run while 0MQ holds the data
+	runtime.GC()
+
+	msg, err := sb.RecvBytes(0)
+	if err != nil {
+		t.Fatal("RecvBytes:", err)
+	}
+	if len(msg) != len(data) {
+		t.Fatalf("Expected %d bytes received, got %d", len(data), len(msg))
+	}
+	for i := range msg {
+		if msg[i] != byte(i) {
+			t.Fatalf("Wrong byte %d at %d", msg[i], i)
+		}
+	}
+	select {
+	case <-freed:
+	case <-time.After(time.Second):
+		t.Error("free not called after message was received")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestSendFromReadError(t *testing.T) {
+
+	sb, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sb.Close()
+	sc, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sc.Close()
+	if err := sb.Bind("inproc://sendfrom"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+	if err := sc.Connect("inproc://sendfrom"); err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	r := io.MultiReader(strings.NewReader("abcdefg"), failingReader{})
+	n, err := sc.SendFrom(r, 3, 0)
+	if err == nil || err.Error() != "read failed" {
+		t.Errorf("Expected read error, got %v", err)
+	}
+	if n != 6 {
+		t.Errorf("Expected 6 bytes sent, got %d", n)
+	}
+	// the next message must not be glued onto the truncated one
+	if _, err := sc.Send("next", 0); err != nil {
+		t.Fatal("Send:", err)
+	}
+
+	sb.SetRcvtimeo(time.Second)
+	msg, err := sb.RecvMessage(0)
+	if err != nil {
+		t.Fatal("RecvMessage:", err)
+	}
+	if fmt.Sprint(msg) != "[abc def]" {
+		t.Errorf("Expected [abc def], got %q", msg)
+	}
+	msg, err = sb.RecvMessage(0)
+	if err != nil {
+		t.Fatal("RecvMessage:", err)
+	}
+	if fmt.Sprint(msg) != "[next]" {
+		t.Errorf("Expected [next], got %q", msg)
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+
+	sb, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sb.Close()
+	sc, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sc.Close()
+	if err := sb.Bind("inproc://framereader"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+	if err := sc.Connect("inproc://framereader"); err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	data := strings.Repeat("0123456789", 1000)
+	if _, err := sc.SendFrom(strings.NewReader(data), 999, 0); err != nil {
+		t.Fatal("SendFrom:", err)
+	}
+	if _, err := sc.SendMessage("second", "message"); err != nil {
+		t.Fatal("SendMessage:", err)
+	}
+
+	sb.SetRcvtimeo(time.Second)
+	r := sb.FrameReader()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal("ReadAll:", err)
+	}
+	if string(b) != data {
+		t.Errorf("Expected %d bytes, got %d bytes", len(data), len(b))
+	}
+	// the next read starts on the next message
+	b, err = io.ReadAll(r)
+	if err != nil {
+		t.Fatal("ReadAll:", err)
+	}
+	if string(b) != "secondmessage" {
+		t.Errorf("Expected \"secondmessage\", got %q", b)
+	}
+}
+
+func TestSafeSocketParts(t *testing.T) {
+
+	sb, err := NewSafeSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSafeSocket:", err)
+	}
+	defer sb.Close()
+	sc, err := NewSafeSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSafeSocket:", err)
+	}
+	defer sc.Close()
+	port, err := sb.BindTCP("127.0.0.1", 0)
+	if err != nil {
+		t.Fatal("BindTCP:", err)
+	}
+	if err := sc.Connect(fmt.Sprintf("tcp://127.0.0.1:%d", port)); err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	if _, err := sc.SendMore([]byte("one")); err != nil {
+		t.Fatal("SendMore:", err)
+	}
+	if _, err := sc.SendLast([]byte("two")); err != nil {
+		t.Fatal("SendLast:", err)
+	}
+	for _, expected := range []string{"one", "two"} {
+		data, ok, err := sb.RecvTimeout(time.Second, 0)
+		if err != nil || !ok {
+			t.Fatalf("RecvTimeout: %v, %v", ok, err)
+		}
+		if string(data) != expected {
+			t.Errorf("Expected %q, got %q", expected, data)
+		}
+	}
+	if n, err := sb.Drain(); n != 0 || err != nil {
+		t.Errorf("Drain: %d, %v", n, err)
+	}
+}
+
+func TestSendBatch(t *testing.T) {
+
+	sb, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sb.Close()
+	sc, err := NewSocket(PAIR)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sc.Close()
+	sb.SetRcvhwm(10)
+	sc.SetSndhwm(10)
+	if err := sb.Bind("inproc://batch"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+	if err := sc.Connect("inproc://batch"); err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	frames := [][]byte{[]byte("one"), []byte{}, []byte("three")}
+	if n, err := sc.SendBatch(frames, 0); n != 3 || err != nil {
+		t.Fatalf("SendBatch: %d, %v", n, err)
+	}
+	for _, frame := range frames {
+		msg, err := sb.RecvMessageBytes(0)
+		if err != nil {
+			t.Fatal("RecvMessageBytes:", err)
+		}
+		if len(msg) != 1 || string(msg[0]) != string(frame) {
+			t.Errorf("Expected [%q], got %q", frame, msg)
+		}
+	}
+
+	// more than the high water mark, nothing is read
+	frames = make([][]byte, 100)
+	for i := range frames {
+		frames[i] = []byte(fmt.Sprint(i))
+	}
+	n, err := sc.SendBatch(frames, DONTWAIT)
+	if err != ErrEAGAIN {
+		t.Errorf("Expected EAGAIN, got %v", err)
+	}
+	if n < 1 || n >= len(frames) {
+		t.Fatalf("Expected part of the batch sent, got %d", n)
+	}
+	for i := 0; i < n; i++ {
+		msg, err := sb.Recv(DONTWAIT)
+		if err != nil {
+			t.Fatalf("Recv %d: %v", i, err)
+		}
+		if msg != fmt.Sprint(i) {
+			t.Errorf("Expected %d, got %q", i, msg)
+		}
+	}
+	if _, err := sb.Recv(DONTWAIT); err != ErrEAGAIN {
+		t.Errorf("Expected no more than %d messages, got %v", n, err)
+	}
+}
+
+func TestRecvMessageCapped(t *testing.T) {
+
+	server, client, err := NewInprocPair()
+	if err != nil {
+		t.Fatal("NewInprocPair:", err)
+	}
+	defer server.Close()
+	defer client.Close()
+
+	for _, parts := range [][]string{{"a", "b", "c", "d"}, {"12345", "67890"}, {"ok", "fits"}} {
+		if _, err := client.SendMessage(parts); err != nil {
+			t.Fatal("SendMessage:", err)
+		}
+	}
+
+	if _, err := server.RecvMessageCapped(3, 0); err == nil {
+		t.Error("Expected error for too many parts")
+	}
+	if _, err := server.RecvMessageCapped(0, 8); err == nil {
+		t.Error("Expected error for too many bytes")
+	}
+	// the rest of each message was discarded
+	msg, err := server.RecvMessageCapped(2, 6)
+	if err != nil {
+		t.Fatal("RecvMessageCapped:", err)
+	}
+	if len(msg) != 2 || string(msg[0]) != "ok" || string(msg[1]) != "fits" {
+		t.Errorf("Expected [ok fits], got %q", msg)
+	}
+}
+
+func TestRecvAuto(t *testing.T) {
+
+	server, client, err := NewInprocPair()
+	if err != nil {
+		t.Fatal("NewInprocPair:", err)
+	}
+	defer server.Close()
+	defer client.Close()
+
+	SetRecvCopyThreshold(10)
+	defer SetRecvCopyThreshold(-1)
+
+	small := "small"
+	large := strings.Repeat("large", 100)
+	for _, s := range []string{small, large} {
+		if _, err := client.Send(s, 0); err != nil {
+			t.Fatal("Send:", err)
+		}
+	}
+
+	data, msg, err := server.RecvAuto(0)
+	if err != nil {
+		t.Fatal("RecvAuto:", err)
+	}
+	if msg != nil {
+		t.Error("Expected small part to be copied")
+	}
+	if string(data) != small {
+		t.Errorf("Expected %q, got %q", small, data)
+	}
+
+	data, msg, err = server.RecvAuto(0)
+	if err != nil {
+		t.Fatal("RecvAuto:", err)
+	}
+	if msg == nil {
+		t.Fatal("Expected large part not to be copied")
+	}
+	if string(data) != large {
+		t.Errorf("Expected %d bytes, got %q", len(large), data)
+	}
+	if msg.Size() != len(large) {
+		t.Errorf("Expected message size %d, got %d", len(large), msg.Size())
+	}
+	if err := msg.Close(); err != nil {
+		t.Error("msg.Close:", err)
+	}
+}
+
+func TestRequest(t *testing.T) {
+
+	server, err := NewSocket(REP)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer server.Close()
+	client, err := NewSocket(REQ)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer client.Close()
+	if err := server.Bind("inproc://request"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+	if err := client.Connect("inproc://request"); err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		msg, err := server.RecvMessageBytes(0)
+		if err != nil {
+			t.Error("server.RecvMessageBytes:", err)
+			return
+		}
+		if _, err := server.SendMessage("reply to", msg); err != nil {
+			t.Error("server.SendMessage:", err)
+		}
+	}()
+	reply, err := client.Request([][]byte{[]byte("hello")}, time.Second, 0)
+	if err != nil {
+		t.Fatal("Request:", err)
+	}
+	if len(reply) != 2 || string(reply[0]) != "reply to" || string(reply[1]) != "hello" {
+		t.Errorf("Expected [reply to hello], got %q", reply)
+	}
+	<-done
+}
+
+func TestRequestTimeout(t *testing.T) {
+
+	client, err := NewSocket(REQ)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer client.Close()
+	if err := client.Connect("tcp://127.0.0.1:9996"); err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	start := time.Now()
+	_, err = client.Request([][]byte{[]byte("hello")}, 20*time.Millisecond, 2)
+	if err == nil || err.Error() != "No reply after 3 attempts" {
+		t.Errorf("Expected error after 3 attempts, got %v", err)
+	}
+	if d := time.Since(start); d < 60*time.Millisecond {
+		t.Errorf("Expected 3 timeouts of 20ms, returned after %v", d)
+	}
+	// the reopened socket is still connected and usable
+	if _, err := client.Send("again", DONTWAIT); err != nil && err != ErrEAGAIN {
+		t.Errorf("Send after Request: %v", err)
+	}
+}
+
+func TestSocketPool(t *testing.T) {
+
+	server, err := NewSocket(REP)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer server.Close()
+	if err := server.Bind("inproc://socketpool"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < 4; i++ {
+			msg, err := server.Recv(0)
+			if err != nil {
+				t.Error("server.Recv:", err)
+				return
+			}
+			if _, err := server.Send("re: "+msg, 0); err != nil {
+				t.Error("server.Send:", err)
+				return
+			}
+		}
+	}()
+
+	pool := NewSocketPool(REQ, "inproc://socketpool", 2)
+	var first *Socket
+	for i := 0; i < 4; i++ {
+		soc, err := pool.Get()
+		if err != nil {
+			t.Fatal("Get:", err)
+		}
+		if first == nil {
+			first = soc
+		} else if soc != first {
+			t.Error("Expected the idle socket to be reused")
+		}
+		if _, err := soc.Send(fmt.Sprint(i), 0); err != nil {
+			t.Fatal("Send:", err)
+		}
+		soc.SetRcvtimeo(time.Second)
+		reply, err := soc.Recv(0)
+		if err != nil {
+			t.Fatal("Recv:", err)
+		}
+		if expected := fmt.Sprint("re: ", i); reply != expected {
+			t.Errorf("Expected %q, got %q", expected, reply)
+		}
+		pool.Put(soc)
+	}
+	<-done
+
+	if err := pool.Close(); err != nil {
+		t.Error("Close:", err)
+	}
+	if _, err := pool.Get(); err != errPoolClosed {
+		t.Errorf("Expected errPoolClosed from Get after Close, got %v", err)
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+
+	ctx, err := NewContext()
+	if err != nil {
+		t.Fatal("NewContext:", err)
+	}
+	defer ctx.Term()
+
+	pool, err := NewWorkerPool(ctx, "inproc://wp-frontend", "inproc://wp-backend")
+	if err != nil {
+		t.Fatal("NewWorkerPool:", err)
+	}
+	ran := make(chan error)
+	go func() {
+		ran <- pool.Run()
+	}()
+
+	worker, err := ctx.NewSocket(REQ)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer worker.Close()
+	worker.SetLinger(0)
+	if err := worker.Connect("inproc://wp-backend"); err != nil {
+		t.Fatal("worker.Connect:", err)
+	}
+	if _, err := worker.Send("READY", 0); err != nil {
+		t.Fatal("worker.Send:", err)
+	}
+	workerDone := make(chan bool)
+	go func() {
+		defer close(workerDone)
+		msg, err := worker.RecvMessage(0)
+		if err != nil {
+			t.Error("worker.RecvMessage:", err)
+			return
+		}
+		// envelope of the client, then the request
+		if len(msg) != 3 || msg[2] != "hello" {
+			t.Errorf("worker: expected [id \"\" hello], got %q", msg)
+			return
+		}
+		if _, err := worker.SendMessage(msg[0], "", "world"); err != nil {
+			t.Error("worker.SendMessage:", err)
+		}
+	}()
+
+	client, err := ctx.NewSocket(REQ)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer client.Close()
+	client.SetLinger(0)
+	if err := client.Connect("inproc://wp-frontend"); err != nil {
+		t.Fatal("client.Connect:", err)
+	}
+	if _, err := client.Send("hello", 0); err != nil {
+		t.Fatal("client.Send:", err)
+	}
+	client.SetRcvtimeo(time.Second)
+	reply, err := client.Recv(0)
+	if err != nil {
+		t.Fatal("client.Recv:", err)
+	}
+	if reply != "world" {
+		t.Errorf("Expected \"world\", got %q", reply)
+	}
+	<-workerDone
+
+	if err := pool.Stop(); err != nil {
+		t.Error("Stop:", err)
+	}
+	if err := <-ran; err != nil {
+		t.Error("Run:", err)
+	}
+	if err := pool.Stop(); err != errPoolStopped {
+		t.Errorf("Expected errPoolStopped from second Stop, got %v", err)
+	}
+}
+
+func TestServe(t *testing.T) {
+
+	server, err := NewSocket(REP)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer server.Close()
+	client, err := NewSocket(REQ)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer client.Close()
+	if err := server.Bind("inproc://serve"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+	if err := client.Connect("inproc://serve"); err != nil {
+		t.Fatal("Connect:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() {
+		served <- server.Serve(ctx, func(request [][]byte) ([][]byte, error) {
+			if string(request[0]) == "fail" {
+				return nil, errors.New("failed")
+			}
+			return [][]byte{[]byte("re:"), request[0]}, nil
+		})
+	}()
+
+	client.SetRcvtimeo(time.Second)
+	for _, c := range []struct{ request, reply string }{
+		{"hello", "[re: hello]"},
+		{"fail", "[failed]"},
+	} {
+		if _, err := client.Send(c.request, 0); err != nil {
+			t.Fatal("Send:", err)
+		}
+		reply, err := client.RecvMessage(0)
+		if err != nil {
+			t.Fatal("RecvMessage:", err)
+		}
+		if fmt.Sprint(reply) != c.reply {
+			t.Errorf("Expected %s, got %q", c.reply, reply)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled from Serve, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Serve didn't return after cancel")
+	}
+}
+
+func TestNewMonitorSocket(t *testing.T) {
+
+	rep, err := NewSocket(REP)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer rep.Close()
+	if err := rep.Monitor("inproc://monitor.test", EVENT_LISTENING); err != nil {
+		t.Fatal("Monitor:", err)
+	}
+	defer rep.Monitor("", 0)
+
+	mon, err := NewMonitorSocket("inproc://monitor.test")
+	if err != nil {
+		t.Fatal("NewMonitorSocket:", err)
+	}
+	defer mon.Close()
+	if typ, _ := mon.GetType(); typ != PAIR {
+		t.Errorf("Expected PAIR socket, got %v", typ)
+	}
+
+	if _, err := rep.BindTCP("127.0.0.1", 0); err != nil {
+		t.Fatal("BindTCP:", err)
+	}
+	// an event arrives on the monitor socket
+	mon.SetRcvtimeo(time.Second)
+	if _, err := mon.RecvBytes(0); err != nil {
+		t.Error("No event received:", err)
+	}
+}
+
+func TestWaitForConnectTCP(t *testing.T) {
+
+	server, err := NewSocket(ROUTER)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer server.Close()
+	client, err := NewSocket(DEALER)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer client.Close()
+	client.SetLinger(0)
+	port, err := server.BindTCP("127.0.0.1", 0)
+	if err != nil {
+		t.Fatal("BindTCP:", err)
+	}
+
+	// a host name, not the address 0MQ reports in the event
+	if err := client.WaitForConnect(fmt.Sprintf("tcp://localhost:%d", port), time.Second); err != nil {
+		t.Fatal("WaitForConnect:", err)
+	}
+
+	// nothing listening
+	start := time.Now()
+	if err := client.WaitForConnect("tcp://127.0.0.1:9995", 50*time.Millisecond); err == nil {
+		t.Error("Expected timeout without server")
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("Returned after %v, before the timeout", d)
+	}
+}
+
+func TestConnectWaitTCP(t *testing.T) {
+
+	server, err := NewSocket(PULL)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer server.Close()
+	client, err := NewSocket(PUSH)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer client.Close()
+	client.SetLinger(0)
+	port, err := server.BindTCP("127.0.0.1", 0)
+	if err != nil {
+		t.Fatal("BindTCP:", err)
+	}
+
+	if err := client.ConnectWait(fmt.Sprintf("tcp://localhost:%d", port), time.Second); err != nil {
+		t.Fatal("ConnectWait:", err)
+	}
+	// still connected
+	if _, err := client.Send("hello", DONTWAIT); err != nil {
+		t.Fatal("Send:", err)
+	}
+	server.SetRcvtimeo(time.Second)
+	if msg, err := server.Recv(0); err != nil || msg != "hello" {
+		t.Errorf("Recv: %q, %v", msg, err)
+	}
+
+	// nothing listening, so disconnected again after the timeout
+	other, err := NewSocket(PUSH)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer other.Close()
+	other.SetLinger(0)
+	if err := other.ConnectWait("tcp://localhost:9995", 50*time.Millisecond); err == nil {
+		t.Fatal("Expected timeout without server")
+	}
+	if _, err := other.Send("lost", DONTWAIT); err != ErrEAGAIN {
+		t.Errorf("Expected EAGAIN without connection, got %v", err)
+	}
+}
+
+func TestSocketPoolNewSocketFails(t *testing.T) {
+
+	ctx, err := NewContext()
+	if err != nil {
+		t.Fatal("NewContext:", err)
+	}
+	defer ctx.Term()
+
+	pool := ctx.NewSocketPool(REQ, "inproc://socketpoolfails", 1)
+	defer pool.Close()
+	// every new socket fails
+	ctx.SetDefaultSockOpt(OPT_SNDHWM, -1)
+	for i := 0; i < 2; i++ {
+		if soc, err := pool.Get(); err == nil || soc != nil {
+			t.Errorf("Expected error from Get, got %v, %v", soc, err)
+		}
+	}
+}
+
+func TestHeartbeaterRecv(t *testing.T) {
+
+	server, client, err := NewInprocPair()
+	if err != nil {
+		t.Fatal("NewInprocPair:", err)
+	}
+	defer server.Close()
+	defer client.Close()
+
+	hb := NewHeartbeater(client, 10*time.Millisecond, time.Second, []byte("PING"))
+	defer hb.Stop()
+	for _, s := range []string{"PING", "one", "PING", "two"} {
+		if _, err := server.Send(s, 0); err != nil {
+			t.Fatal("Send:", err)
+		}
+	}
+
+	// not read for a while, so they wait in the socket
+	time.Sleep(50 * time.Millisecond)
+	for _, expected := range []string{"one", "two"} {
+		select {
+		case msg := <-hb.Recv():
+			if len(msg) != 1 || string(msg[0]) != expected {
+				t.Errorf("Expected [%s], got %q", expected, msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Nothing received, expected %q", expected)
+		}
+	}
+
+	hb.Stop()
+	if _, ok := <-hb.Recv(); ok {
+		t.Error("Expected Recv channel closed after Stop")
+	}
+}
+
+func TestReactorRunContext(t *testing.T) {
+
+	server, client, err := NewInprocPair()
+	if err != nil {
+		t.Fatal("NewInprocPair:", err)
+	}
+	defer server.Close()
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got string
+	reactor := NewReactor()
+	reactor.AddSocketHandler(server, POLLIN, func(soc *Socket, state State) error {
+		msg, err := soc.Recv(0)
+		got = msg
+		cancel()
+		return err
+	})
+	if _, err := client.Send("hello", 0); err != nil {
+		t.Fatal("Send:", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- reactor.RunContext(ctx)
+	}()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunContext didn't return after cancel")
+	}
+	if got != "hello" {
+		t.Errorf("Expected handler to receive \"hello\", got %q", got)
+	}
+
+	// cancelled while polling, nothing to handle
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		done <- reactor.RunContext(ctx)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunContext didn't return after cancel while polling")
+	}
+}
+
+func TestCloneSubscriptions(t *testing.T) {
+
+	pub, err := NewSocket(PUB)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer pub.Close()
+	if err := pub.Bind("inproc://clonesubscriptions"); err != nil {
+		t.Fatal("Bind:", err)
+	}
+
+	sub, err := NewSocket(SUB)
+	if err != nil {
+		t.Fatal("NewSocket:", err)
+	}
+	defer sub.Close()
+	if err := sub.SetSockOptString(OPT_SUBSCRIBE, "a"); err != nil {
+		t.Fatal("SetSockOptString subscribe:", err)
+	}
+	if err := sub.SetSockOptString(OPT_SUBSCRIBE, "b"); err != nil {
+		t.Fatal("SetSockOptString subscribe:", err)
+	}
+	if err := sub.SetSockOptString(OPT_UNSUBSCRIBE, "a"); err != nil {
+		t.Fatal("SetSockOptString unsubscribe:", err)
+	}
+	clone, err := sub.Clone()
+	if err != nil {
+		t.Fatal("Clone:", err)
+	}
+	defer clone.Close()
+	if err := clone.Connect("inproc://clonesubscriptions"); err != nil {
+		t.Fatal("Connect:", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	for _, s := range []string{"a-no", "b-yes"} {
+		if _, err := pub.Send(s, 0); err != nil {
+			t.Fatal("Send:", err)
+		}
+	}
+	clone.SetRcvtimeo(time.Second)
+	msg, err := clone.Recv(0)
+	if err != nil {
+		t.Fatal("Recv:", err)
+	}
+	if msg != "b-yes" {
+		t.Errorf("Expected \"b-yes\", got %q", msg)
+	}
+}